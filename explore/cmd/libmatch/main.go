@@ -1,18 +1,25 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"io"
-	"io/fs"
 	"os"
-	"path/filepath"
 	"slices"
+
+	"github.com/dexter3k/watre/explore/ext/exe"
+	"github.com/dexter3k/watre/explore/ext/mmap"
+	"github.com/dexter3k/watre/explore/ext/project"
+	"github.com/dexter3k/watre/explore/ext/report"
+	"github.com/dexter3k/watre/explore/ext/script"
 )
 
 type Matcher struct {
 	target []byte
 
 	omfLibs []*OmfLibrary
+	// Parsed libraries refer to their mappings, these are closed along with the matcher
+	mappings []*mmap.File
 }
 
 func NewMatcher(target []byte) Matcher {
@@ -21,32 +28,21 @@ func NewMatcher(target []byte) Matcher {
 	}
 }
 
-func (m *Matcher) CheckLibDirectory(dir string) error {
-
-	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
-		if err != nil {
-			return fmt.Errorf("checking lib directory: %w", err)
-		}
-
-		if d.IsDir() {
-			if filepath.Clean(path) != filepath.Clean(dir) {
-				return fs.SkipDir
-			}
-			return nil
-		}
-
-		ext := filepath.Ext(d.Name())
-		if ext != ".obj" && ext != ".lib" {
-			return nil
-		}
-		return m.CheckLibOrObjFile(path)
-	})
-
-	return err
+func (m *Matcher) Close() {
+	for _, mapping := range m.mappings {
+		mapping.Close()
+	}
+	m.mappings = nil
 }
 
 func (m *Matcher) CheckLibOrObjFile(path string) error {
-	data := loadBinary(path)
+	mapping, err := mmap.Open(path)
+	if err != nil {
+		return err
+	}
+	m.mappings = append(m.mappings, mapping)
+
+	data := mapping.Data
 	if data[0] == 0xf0 && data[1] == 0x01 {
 		return fmt.Errorf("Unsupported library format")
 	} else if data[0] == 0xf0 {
@@ -58,7 +54,6 @@ func (m *Matcher) CheckLibOrObjFile(path string) error {
 	} else {
 		return fmt.Errorf("Invalid file format")
 	}
-	return nil
 }
 
 func (m *Matcher) CheckOMF(path string, data []byte) error {
@@ -80,25 +75,49 @@ func (m *Matcher) CheckCOFF(path string, data []byte) error {
 }
 
 func main() {
-	// Run with two+ params: target.exe [a list of all lib directories]
-	// Prints JSON with information on where which lib was found
-	if len(os.Args) < 3 {
-		fmt.Printf("Usage: libmatch target.exe [list of lib dirs]")
-		os.Exit(1)
+	// Run with two+ params: target.exe [a list of all libs or lib directories]
+	// Prints where which lib was found, and writes the same as a JSON report if asked to
+	projectPath := flag.String("project", "", "Read the target, libraries and exclusions from a project file")
+	reportPath := flag.String("report", "", "Write a JSON report, overrides the one named by the project")
+	idcPath := flag.String("idc", "", "Write an IDA script naming what was matched, overrides the one named by the project")
+	ghidraPath := flag.String("ghidra", "", "Write a Ghidra Python script naming what was matched, overrides the one named by the project")
+	flag.Parse()
+
+	var proj *project.Project
+	if *projectPath != "" {
+		var err error
+		proj, err = project.Load(*projectPath)
+		check(err)
+	} else {
+		if flag.NArg() < 2 {
+			fmt.Printf("Usage: libmatch [-project file.json] [-report file.json] [-idc file.idc] [-ghidra file.py] target.exe [list of libs or lib dirs]\n")
+			os.Exit(1)
+		}
+		proj = &project.Project{
+			Target:    flag.Arg(0),
+			Libraries: flag.Args()[1:],
+		}
+	}
+	if *reportPath != "" {
+		proj.Output.Report = *reportPath
+	}
+	if *idcPath != "" {
+		proj.Output.IDC = *idcPath
+	}
+	if *ghidraPath != "" {
+		proj.Output.Ghidra = *ghidraPath
 	}
 
-	target := loadBinary(os.Args[1])
-	matcher := NewMatcher(target)
-
-	for _, path := range os.Args[2:] {
-		info, err := os.Stat(path)
-		check(err)
+	target, err := mmap.Open(proj.Target)
+	check(err)
+	defer target.Close()
+	matcher := NewMatcher(target.Data)
+	defer matcher.Close()
 
-		if info.IsDir() {
-			check(matcher.CheckLibDirectory(path))
-		} else {
-			check(fmt.Errorf("Specifying files as libs/obj is not implemented"))
-		}
+	paths, err := proj.LibraryFiles()
+	check(err)
+	for _, path := range paths {
+		check(matcher.CheckLibOrObjFile(path))
 	}
 
 	objects := 0
@@ -108,33 +127,14 @@ func main() {
 
 	// fmt.Printf("%d OMF libs, %d objects loaded\n", len(matcher.omfLibs), objects)
 
-	exe, err := LoadWatcomExe(os.Args[1])
+	watcom, err := exe.LoadWatcomExe(proj.Target)
 	check(err)
 
-	fmt.Printf("%02x\n", exe.Code[:32])
-
-	excludeLibs := map[string]struct{}{
-		"C:\\WATCOM_10_6\\lib386\\math387s.lib":     struct{}{},
-		"C:\\WATCOM_10_6\\lib386\\math3r.lib":       struct{}{},
-		"C:\\WATCOM_10_6\\lib386\\math3s.lib":       struct{}{},
-		"C:\\WATCOM_10_6\\lib386\\nt\\clib3s.lib":   struct{}{},
-		"C:\\WATCOM_10_6\\lib386\\nt\\pfsn3r.lib":   struct{}{},
-		"C:\\WATCOM_10_6\\lib386\\nt\\pfsnmt3r.lib": struct{}{},
-		"C:\\WATCOM_10_6\\lib386\\nt\\pfsx3r.lib":   struct{}{},
-		"C:\\WATCOM_10_6\\lib386\\nt\\pfsxmt3r.lib": struct{}{},
-		"C:\\WATCOM_10_6\\lib386\\plbx3r.lib":       struct{}{},
-		"C:\\WATCOM_10_6\\lib386\\plbxmt3r.lib":     struct{}{},
-		"C:\\WATCOM_10_6\\lib386\\plib3r.lib":       struct{}{},
-		"C:\\WATCOM_10_6\\lib386\\plibmt3r.lib":     struct{}{},
-	}
-
 	foundMatches := map[uint32]uint32{}
+	rep := report.New(proj.Target)
+	rep.Bounds = report.Range{Start: watcom.CodeBase, End: watcom.CodeBase + uint32(len(watcom.Code))}
 
 	for _, lib := range matcher.omfLibs {
-		if _, found := excludeLibs[lib.Path]; found {
-			continue
-		}
-
 		for _, obj := range lib.Objects {
 			for _, seg := range obj.Segments {
 				if seg.Section != "CODE" || len(seg.Data) - len(seg.Fixups) * 4 < 9 {
@@ -142,11 +142,11 @@ func main() {
 				}
 
 codeLinearSearch:
-				for i := 0; i < len(exe.Code); i++ {
+				for i := 0; i < len(watcom.Code); i++ {
 					fixupPtr := 0
 
 					for j := 0; j < len(seg.Data); j++ {
-						if i + j >= len(exe.Code) {
+						if i + j >= len(watcom.Code) {
 							continue codeLinearSearch
 						}
 
@@ -164,13 +164,21 @@ codeLinearSearch:
 							}
 						}
 
-						if exe.Code[i + j] != seg.Data[j] {
+						if watcom.Code[i + j] != seg.Data[j] {
 							continue codeLinearSearch
 						}
 					}
 
 					// We matched! Try finding related export
-					fmt.Printf("0x%06x-0x%06x: %q %q\n", exe.CodeBase + uint32(i), exe.CodeBase + uint32(i) + uint32(len(seg.Data)), lib.Path, obj.Name)
+					fmt.Printf("0x%06x-0x%06x: %q %q\n", watcom.CodeBase + uint32(i), watcom.CodeBase + uint32(i) + uint32(len(seg.Data)), lib.Path, obj.Name)
+					rep.Placements = append(rep.Placements, report.Placement{
+						Object:     obj.Name,
+						Library:    lib.Path,
+						Section:    seg.Section,
+						Range:      report.Range{Start: watcom.CodeBase + uint32(i), End: watcom.CodeBase + uint32(i) + uint32(len(seg.Data))},
+						Alignment:  1,
+						Confidence: "bytes",
+					})
 					if _, found := foundMatches[uint32(i)]; found {
 						foundMatches[uint32(i)] = max(foundMatches[uint32(i)], uint32(len(seg.Data)))
 					}
@@ -190,17 +198,17 @@ codeLinearSearch:
 	chunk := []byte{}
 	chunkBase := uint32(0x10)
 
-	for pc := uint32(0x10); pc < uint32(len(exe.Code)); pc++ {
+	for pc := uint32(0x10); pc < uint32(len(watcom.Code)); pc++ {
 		if len(sortedMatches) > 0 && sortedMatches[0] == pc {
 			if len(chunk) > 0 {
-				fmt.Printf("%08x-%08x: %02x\n", chunkBase + exe.CodeBase, chunkBase + exe.CodeBase + uint32(len(chunk)), chunk)
+				fmt.Printf("%08x-%08x: %02x\n", chunkBase + watcom.CodeBase, chunkBase + watcom.CodeBase + uint32(len(chunk)), chunk)
+				rep.Unmatched = append(rep.Unmatched, report.Region{Section: "CODE", Range: report.Range{Start: chunkBase + watcom.CodeBase, End: chunkBase + watcom.CodeBase + uint32(len(chunk))}})
 			}
 
 			skipTo := sortedMatches[0] + foundMatches[sortedMatches[0]]
 			for pc < skipTo {
 				for len(sortedMatches) > 0 && sortedMatches[0] <= skipTo {
 					skipTo = max(skipTo, sortedMatches[0] + foundMatches[sortedMatches[0]])
-					fmt.Printf("Skipping %08x -> %08x\n", sortedMatches[0] + exe.CodeBase, skipTo + exe.CodeBase)
 					sortedMatches = sortedMatches[1:]
 				}
 				pc++
@@ -210,21 +218,34 @@ codeLinearSearch:
 			chunkBase = pc
 		}
 
-		chunk = append(chunk, exe.Code[pc])
+		chunk = append(chunk, watcom.Code[pc])
 	}
 
 	if len(chunk) > 0 {
-		fmt.Printf("%08x-%08x: %02x\n", chunkBase + exe.CodeBase, chunkBase + exe.CodeBase + uint32(len(chunk)), chunk)
+		fmt.Printf("%08x-%08x: %02x\n", chunkBase + watcom.CodeBase, chunkBase + watcom.CodeBase + uint32(len(chunk)), chunk)
+		rep.Unmatched = append(rep.Unmatched, report.Region{Section: "CODE", Range: report.Range{Start: chunkBase + watcom.CodeBase, End: chunkBase + watcom.CodeBase + uint32(len(chunk))}})
 	}
+
+	writeOutput(proj.Output.Report, rep.Write)
+	writeOutput(proj.Output.IDC, func(w io.Writer) error {
+		return script.WriteIDC(w, rep)
+	})
+	writeOutput(proj.Output.Ghidra, func(w io.Writer) error {
+		return script.WriteGhidra(w, rep)
+	})
 }
 
-func loadBinary(path string) []byte {
-	f, err := os.Open(path)
-	check(err)
-	defer f.Close()
-	d, err := io.ReadAll(f)
+// Writes one of the outputs named by the project, if it names it
+func writeOutput(path string, write func(io.Writer) error) {
+	if path == "" {
+		return
+	}
+
+	f, err := os.Create(path)
 	check(err)
-	return d
+	check(write(f))
+	// Failed writes may only show up once the file is closed
+	check(f.Close())
 }
 
 func check(err error) {
@@ -1,91 +1,107 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	"encoding/binary"
 	"io"
+	"maps"
 	"os"
 	"runtime/pprof"
+	"slices"
+	"strings"
 
+	"github.com/dexter3k/watre/explore/ext/exe"
+	"github.com/dexter3k/watre/explore/ext/mmap"
+	"github.com/dexter3k/watre/explore/ext/format"
+	"github.com/dexter3k/watre/explore/ext/match"
 	"github.com/dexter3k/watre/explore/ext/omf"
+	"github.com/dexter3k/watre/explore/ext/project"
+	"github.com/dexter3k/watre/explore/ext/report"
+	"github.com/dexter3k/watre/explore/ext/script"
 )
 
-type WatcomExe struct {
-	CodeBase uint32
-	Code     []byte
+// Repeatable -layout flag
+type layoutOverrides []string
 
-	DataBase uint32
-	Data     []byte
-
-	BssBase   uint32
-	BssLength uint32
+func (l *layoutOverrides) String() string {
+	return strings.Join(*l, ",")
 }
 
-func LoadWatcomExe(path string) (WatcomExe, error) {
-	exe := WatcomExe{}
-
-	f, err := os.Open(path)
-	if err != nil {
-		return exe, err
-	}
-	d, err := io.ReadAll(f)
-	f.Close()
-	if err != nil {
-		return exe, err
-	}
-
-	imageBase := binary.LittleEndian.Uint32(d[0xb4:][:4])
-
-	codeBase := imageBase + binary.LittleEndian.Uint32(d[0x184:][:4])
-	codeOffset := binary.LittleEndian.Uint32(d[0x18c:][:4])
-	codeSize := binary.LittleEndian.Uint32(d[0x188:][:4])
-
-	dataBase := imageBase + binary.LittleEndian.Uint32(d[0x1ac:][:4])
-	dataOffset := binary.LittleEndian.Uint32(d[0x1b4:][:4])
-	dataSize := binary.LittleEndian.Uint32(d[0x1b0:][:4])
-
-	bssBase := imageBase + binary.LittleEndian.Uint32(d[0x1d4:][:4])
-	// Note that Watcom exes incorrectly list bss size in the raw data size field
-	// instead of virtual size field
-	bssSize := binary.LittleEndian.Uint32(d[0x1d8:][:4])
-
-	exe.CodeBase = codeBase
-	exe.Code = make([]byte, codeSize, codeSize)
-	copy(exe.Code, d[codeOffset:])
-
-	exe.DataBase = dataBase
-	exe.Data = make([]byte, dataSize, dataSize)
-	copy(exe.Data, d[dataOffset:])
-
-	exe.BssBase = bssBase
-	exe.BssLength = bssSize
-
-	return exe, nil
+func (l *layoutOverrides) Set(value string) error {
+	*l = append(*l, value)
+	return nil
 }
 
+// Where the listing goes, stdout unless the project names a file
+var out io.Writer = os.Stdout
+
 func main() {
-	if true {
-        f, err := os.Create("omfmatch.pprof")
-        check(err)
+	var overrides layoutOverrides
+	projectPath := flag.String("project", "", "Read the target, libraries, layout and outputs from a project file")
+	reportPath := flag.String("report", "", "Write a JSON report, overrides the one named by the project")
+	idcPath := flag.String("idc", "", "Write an IDA script naming what was matched, overrides the one named by the project")
+	ghidraPath := flag.String("ghidra", "", "Write a Ghidra Python script naming what was matched, overrides the one named by the project")
+	flag.Var(&overrides, "layout", "Override the layout taken from the image: bounds=LOW-HIGH, selector=SEL or CODE|DATA|BEGDATA|CONST|BSS=BASE+SIZE, in hex; DATA moves CONST along")
+	flag.Parse()
 
-        pprof.StartCPUProfile(f)
-        defer pprof.StopCPUProfile()
-    }
+	var proj *project.Project
+	if *projectPath != "" {
+		var err error
+		proj, err = project.Load(*projectPath)
+		check(err)
+	} else {
+		if flag.NArg() < 2 {
+			fmt.Printf("Usage: omfmatch [-project file.json] [-report file.json] [-idc file.idc] [-ghidra file.py] [-layout override] target.exe [list of omf libs or lib dirs]\n")
+			os.Exit(1)
+		}
+		proj = &project.Project{
+			Target:    flag.Arg(0),
+			Libraries: flag.Args()[1:],
+		}
+	}
+
+	if *reportPath != "" {
+		proj.Output.Report = *reportPath
+	}
+	if *idcPath != "" {
+		proj.Output.IDC = *idcPath
+	}
+	if *ghidraPath != "" {
+		proj.Output.Ghidra = *ghidraPath
+	}
 
-	if len(os.Args) < 3 {
-		fmt.Printf("Usage: omfmatch target.exe [list of omf libs]\n")
-		os.Exit(1)
+	if proj.Output.Text != "" {
+		f, err := os.Create(proj.Output.Text)
+		check(err)
+		defer func() {
+			check(f.Close())
+		}()
+		out = f
+	}
+	if proj.CpuProfile != "" {
+		f, err := os.Create(proj.CpuProfile)
+		check(err)
+		defer func() {
+			check(f.Close())
+		}()
+		check(pprof.StartCPUProfile(f))
+		defer pprof.StopCPUProfile()
 	}
 
+	paths, err := proj.LibraryFiles()
+	check(err)
+
 	objects := []*omf.Object{}
-	for _, path := range os.Args[2:] {
-		data := loadBinary(path)
-		obj, err := omf.Parse(data)
+	for _, path := range paths {
+		obj, err := parseLibrary(path)
 		check(err)
+		for _, object := range obj {
+			object.Library = path
+		}
 
 		objects = append(objects, obj...)
 	}
-	fmt.Printf("%d objects parsed\n", len(objects))
+	fmt.Fprintf(out, "%d objects parsed\n", len(objects))
 
 	// Collect all available exports
 	exports := map[string]string{}
@@ -95,7 +111,7 @@ func main() {
 			for _, segment := range object.Segments[location] {
 				for export, offset := range segment.Exports {
 					if objectName, found := exports[export]; found {
-						fmt.Printf("Export collision: %s:%s:%s:%q is already defined in %s\n", object.Name, location, segment.Name, export, objectName)
+						fmt.Fprintf(out, "Export collision: %s:%s:%s:%q is already defined in %s\n", object.Name, location, segment.Name, export, objectName)
 					} else {
 						exports[export] = fmt.Sprintf("%q:%s:%q", object.Name, location, segment.Name)
 						exportOffsets[export] = offset
@@ -123,41 +139,183 @@ func main() {
 			}
 		}
 	}
-	fmt.Printf("%d imports missing\n", len(missingImports))
+	fmt.Fprintf(out, "%d imports missing\n", len(missingImports))
 
 	// Load the exe
-	exe, err := LoadWatcomExe(os.Args[1])
+	watcom, file, mapping, err := loadTarget(proj.Target)
 	check(err)
-	fmt.Printf("CODE: %08x: %d KiB\n", exe.CodeBase, len(exe.Code) / 1024)
-	fmt.Printf("DATA: %08x: %d KiB\n", exe.DataBase, len(exe.Data) / 1024)
-	fmt.Printf(" BSS: %08x: %d KiB\n", exe.BssBase, exe.BssLength / 1024)
-
-	matchIndividual(
-		objects,
-		map[omf.Location][]byte{
-			omf.LocationText: exe.Code,
-			omf.LocationData: exe.Data,
-			omf.LocationConst: exe.Data,
-			omf.LocationStatic: make([]byte, exe.BssLength),
-			omf.LocationStack: nil,
-		},
-		map[omf.Location]uint32{
-			omf.LocationText: exe.CodeBase,
-			omf.LocationData: exe.DataBase,
-			omf.LocationConst: exe.DataBase,
-			omf.LocationStatic: exe.BssBase,
-			omf.LocationStack: 0,
-		},
-	)
+	defer mapping.Close()
+
+	target := match.NewTarget(watcom)
+	for _, override := range append(proj.Layout(), overrides...) {
+		check(target.Override(override))
+	}
+	for location := omf.Location(0); location < omf.LocationCount; location++ {
+		if region := target.Regions[location]; len(region.Data) > 0 {
+			fmt.Fprintf(out, "%5s: %08x: %d KiB\n", location, region.Base, len(region.Data) / 1024)
+		}
+	}
+	fmt.Fprintf(out, "Bounds: %08x-%08x\n", target.LowAddress, target.HighAddress)
+
+	result := match.Match(target, objects)
+	printResult(result)
+
+	rep := report.Build(proj.Target, target, result)
+	writeOutput(proj.Output.Report, rep.Write)
+	writeOutput(proj.Output.IDC, func(w io.Writer) error {
+		return script.WriteIDC(w, rep)
+	})
+	writeOutput(proj.Output.Ghidra, func(w io.Writer) error {
+		return script.WriteGhidra(w, rep)
+	})
+
+	if file != nil {
+		validateAgainstDebugInfo(file, result.Globals)
+	}
 }
 
-func loadBinary(path string) []byte {
-	f, err := os.Open(path)
+func printResult(result *match.Result) {
+	for _, ambiguity := range result.Ambiguous {
+		fmt.Fprintf(out, "Multiple (%d) matches for %s\n", len(ambiguity.Candidates), ambiguity.Object)
+	}
+	for _, conflict := range result.Conflicting {
+		fmt.Fprintf(out, "Conflicting match for %s\n", conflict.Object)
+		for _, reason := range conflict.Reasons {
+			fmt.Fprintf(out, "   %s\n", reason)
+		}
+	}
+
+	if result.FlatSelector != 0 {
+		fmt.Fprintf(out, "Flat selector: %04x\n", result.FlatSelector)
+	}
+
+	fmt.Fprintf(out, "Link order:")
+	for _, name := range result.LinkOrder {
+		fmt.Fprintf(out, " %s", name)
+	}
+	fmt.Fprintf(out, "\n")
+
+	for _, static := range result.Statics {
+		fmt.Fprintf(out, "BSS %s:%s at %08x+%x (%s)\n", static.Object, static.Segment, static.Address, static.Size, static.Source)
+		for _, sym := range static.Symbols {
+			name := sym.Name
+			if name == "" {
+				name = "(static)"
+			}
+			fmt.Fprintf(out, "   %08x+%x %s\n", sym.Address, sym.Size, name)
+		}
+	}
+	for _, issue := range result.StaticIssues {
+		fmt.Fprintf(out, "BSS: %s\n", issue)
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(result.Locals)) {
+		fmt.Fprintf(out, " - %s: %08x\n", name, result.Locals[name])
+	}
+	for _, name := range slices.Sorted(maps.Keys(result.Globals)) {
+		fmt.Fprintf(out, " - %s: %08x\n", name, result.Globals[name])
+	}
+}
+
+// Compares inferred global addresses to the debug info of the target, if it has any
+func validateAgainstDebugInfo(file *exe.File, globals map[string]uint32) {
+	symbols, err := file.Symbols()
 	check(err)
-	defer f.Close()
-	d, err := io.ReadAll(f)
+	if len(symbols) == 0 {
+		return
+	}
+
+	byName := map[string]exe.Symbol{}
+	byAddress := map[uint32]exe.Symbol{}
+	for _, sym := range symbols {
+		if _, found := byName[sym.Name]; !found || !sym.Local {
+			byName[sym.Name] = sym
+		}
+		if _, found := byAddress[sym.Address]; !found {
+			byAddress[sym.Address] = sym
+		}
+	}
+
+	confirmed, mismatched, unknown := 0, 0, 0
+	for _, name := range slices.Sorted(maps.Keys(globals)) {
+		address := globals[name]
+		sym, found := byName[name]
+		if !found {
+			if other, found := byAddress[address]; found {
+				fmt.Fprintf(out, "Debug info: %s at %08x is named %s\n", name, address, other.Name)
+			}
+			unknown++
+			continue
+		}
+
+		if sym.Address != address {
+			fmt.Fprintf(out, "Debug info: %s inferred at %08x, but is at %08x\n", name, address, sym.Address)
+			mismatched++
+			continue
+		}
+
+		confirmed++
+	}
+
+	fmt.Fprintf(out, "Debug info: %d confirmed, %d mismatched, %d not found\n", confirmed, mismatched, unknown)
+}
+
+// PE targets are also returned as is, with their debug info loaded.
+// Both refer to the returned mapping, close it once done with them.
+func loadTarget(path string) (*exe.WatcomExe, *exe.File, *mmap.File, error) {
+	m, err := mmap.Open(path)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	file, kind, err := format.Parse(m.Data)
+	if err != nil {
+		m.Close()
+		return nil, nil, nil, err
+	}
+
+	img, err := file.Image()
+	if err != nil {
+		m.Close()
+		return nil, nil, nil, err
+	}
+
+	target, err := exe.NewWatcomExe(img)
+	if err != nil {
+		m.Close()
+		return nil, nil, nil, err
+	}
+
+	if kind == format.KindPE {
+		pe := file.(*exe.File)
+		pe.LoadDebugInfo()
+		return target, pe, m, nil
+	}
+	return target, nil, m, nil
+}
+
+// Writes one of the outputs named by the project, if it names it
+func writeOutput(path string, write func(io.Writer) error) {
+	if path == "" {
+		return
+	}
+
+	f, err := os.Create(path)
 	check(err)
-	return d
+	check(write(f))
+	// Failed writes may only show up once the file is closed
+	check(f.Close())
+}
+
+// Parsed objects copy everything they need, so the library is unmapped right away
+func parseLibrary(path string) ([]*omf.Object, error) {
+	m, err := mmap.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer m.Close()
+
+	return omf.Parse(m.Data)
 }
 
 func check(err error) {
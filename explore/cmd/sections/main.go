@@ -5,6 +5,7 @@ import (
 	"os"
 	"encoding/binary"
 	"bytes"
+	"time"
 
 	"github.com/dexter3k/watre/explore/ext/exe"
 )
@@ -22,14 +23,58 @@ func main() {
 	file, err := exe.Read(f)
 	check(err)
 
+	fmt.Printf("Timestamp: %s\n", time.Unix(int64(file.Pe.TimeDateStamp), 0).UTC().Format(time.RFC3339))
+	fmt.Printf("Characteristics: %s\n", file.Pe.Characteristics)
+
 	for _, entry := range file.Sections {
-		fmt.Printf("%8s: %08x+%6x -> %08x+%6x\n",
+		fmt.Printf("%8s: %08x+%6x -> %08x+%6x %s %s\n",
 			entry.Name,
 			entry.RawOffset, entry.RawSize,
 			entry.VirtualAddress, entry.VirtualSize,
+			entry.Characteristics.Protection(), entry.Characteristics,
 		)
 	}
 
+	for _, finding := range file.Validate() {
+		fmt.Printf("%s\n", finding)
+	}
+
+	entries, err := file.DebugDirectory()
+	check(err)
+	for _, entry := range entries {
+		fmt.Printf("Debug directory: %s at %08x+%x", entry.Type, entry.PointerToRawData, entry.SizeOfData)
+		if path, found := file.DebugPath(entry); found {
+			fmt.Printf(" %q", path)
+		}
+		fmt.Printf("\n")
+	}
+
+	seeds, err := file.SeedSymbols()
+	if err != nil {
+		fmt.Printf("Seed symbols: %v\n", err)
+	}
+	for _, sym := range seeds {
+		fmt.Printf("Seed: %08x+%x %s %s\n", sym.Address, sym.Size, sym.Kind, sym.Name)
+	}
+
+	if file.OverlayKind != exe.OverlayNone {
+		fmt.Printf("Overlay: %s at %08x+%x\n", file.OverlayKind, file.OverlayOffset, len(file.Overlay))
+	}
+	if file.Watcom != nil {
+		modules, globals := 0, 0
+		for _, section := range file.Watcom.Sections {
+			modules += len(section.Modules)
+			globals += len(section.Globals)
+		}
+		fmt.Printf("Watcom debug info v%d.%d: %d modules, %d globals\n", file.Watcom.Header.ExeMajor, file.Watcom.Header.ExeMinor, modules, globals)
+	}
+	if file.CodeView != nil {
+		fmt.Printf("CodeView %s debug info: %d modules, %d symbols, %d types\n", file.CodeView.Signature, len(file.CodeView.Modules), len(file.CodeViewSymbols()), len(file.CodeView.Types))
+	}
+	for _, err := range file.DebugErrors {
+		fmt.Printf("Debug info: %v\n", err)
+	}
+
 	if false {
 		/* We should probably be pointing to .idata from IMAGE_IMPORT_DESCRIPTOR or similar */
 		idata := file.GetSection(".idata")
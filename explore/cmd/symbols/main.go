@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/dexter3k/watre/explore/ext/exe"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Printf("Usage: symbols target.exe [addresses to look up]\n")
+		os.Exit(1)
+	}
+
+	file, err := exe.Open(os.Args[1])
+	check(err)
+	defer file.Close()
+
+	file.LoadDebugInfo()
+	for _, err := range file.DebugErrors {
+		fmt.Printf("Debug info: %v\n", err)
+	}
+
+	lines, err := file.Lines()
+	check(err)
+
+	if len(os.Args) > 2 {
+		for _, arg := range os.Args[2:] {
+			address, err := strconv.ParseUint(arg, 16, 32)
+			check(err)
+
+			if line, found := exe.LineAt(lines, uint32(address)); found {
+				fmt.Printf("%08x: %s:%d\n", address, line.File, line.Line)
+			} else {
+				fmt.Printf("%08x: no line info\n", address)
+			}
+		}
+		return
+	}
+
+	units, err := file.DwarfCompileUnits()
+	check(err)
+	for _, unit := range units {
+		fmt.Printf("Unit %s: %08x-%08x %s\n", unit.Name, unit.LowPC, unit.HighPC, unit.Producer)
+	}
+
+	symbols, err := file.Symbols()
+	check(err)
+	for _, sym := range symbols {
+		scope := "global"
+		if sym.Local {
+			scope = "local"
+		}
+		fmt.Printf("%08x+%6x %-8s %-6s %s", sym.Address, sym.Size, sym.Kind, scope, sym.Name)
+		if sym.Type != "" {
+			fmt.Printf(" (%s)", sym.Type)
+		}
+		if sym.Module != "" {
+			fmt.Printf(" in %s", sym.Module)
+		}
+		fmt.Printf("\n")
+	}
+	fmt.Printf("%d symbols, %d lines\n", len(symbols), len(lines))
+}
+
+func check(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,442 @@
+package codeview
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// Locates CodeView info appended at the end of data. The trailer holds the
+// signature and the distance from the end of data back to the start of the info.
+func FindAppended(data []byte) ([]byte, bool) {
+	if len(data) < 8 {
+		return nil, false
+	}
+
+	trailer := data[len(data) - 8:]
+	if !isSignature(trailer[:4]) {
+		return nil, false
+	}
+
+	distance := binary.LittleEndian.Uint32(trailer[4:])
+	if distance < 8 || uint64(distance) > uint64(len(data)) {
+		return nil, false
+	}
+
+	start := data[len(data) - int(distance):]
+	if !isSignature(start[:4]) {
+		return nil, false
+	}
+
+	return start, true
+}
+
+func isSignature(data []byte) bool {
+	return string(data) == "NB09" || string(data) == "NB11"
+}
+
+// Parses CodeView info starting with the NB09 or NB11 signature
+func Parse(data []byte) (*Info, error) {
+	le := binary.LittleEndian
+
+	if len(data) < 8 || !isSignature(data[:4]) {
+		return nil, fmt.Errorf("Unknown CodeView signature")
+	}
+
+	info := &Info{
+		Signature: string(data[:4]),
+	}
+
+	directory := le.Uint32(data[4:])
+	visited := map[uint32]struct{}{}
+	for directory != 0 {
+		if uint64(directory) + 16 > uint64(len(data)) {
+			return nil, fmt.Errorf("CodeView directory is out of bounds")
+		}
+		if _, found := visited[directory]; found {
+			return nil, fmt.Errorf("CodeView directories form a loop at %x", directory)
+		}
+		visited[directory] = struct{}{}
+
+		header := data[directory:]
+		headerSize := uint32(le.Uint16(header))
+		entrySize := uint32(le.Uint16(header[2:]))
+		count := le.Uint32(header[4:])
+		next := le.Uint32(header[8:])
+		if entrySize < 12 || uint64(directory) + uint64(headerSize) + uint64(count) * uint64(entrySize) > uint64(len(data)) {
+			return nil, fmt.Errorf("CodeView directory is out of bounds")
+		}
+
+		entries := data[directory + headerSize:]
+		for i := uint32(0); i < count; i++ {
+			entry := entries[i * entrySize:]
+			info.Directory = append(info.Directory, DirectoryEntry{
+				Type:   SubsectionType(le.Uint16(entry)),
+				Module: le.Uint16(entry[2:]),
+				Offset: le.Uint32(entry[4:]),
+				Size:   le.Uint32(entry[8:]),
+			})
+		}
+
+		// Some linkers end the chain with a directory linking to itself
+		if next == directory {
+			break
+		}
+		directory = next
+	}
+
+	modules := map[uint16]*Module{}
+	getModule := func(index uint16) *Module {
+		if module, found := modules[index]; found {
+			return module
+		}
+		modules[index] = &Module{Index: index}
+		return modules[index]
+	}
+
+	for _, entry := range info.Directory {
+		if uint64(entry.Offset) + uint64(entry.Size) > uint64(len(data)) {
+			return nil, fmt.Errorf("CodeView subsection %03x is out of bounds", uint16(entry.Type))
+		}
+		sub := data[entry.Offset:][:entry.Size]
+
+		var err error
+		switch entry.Type {
+		case SstModule:
+			err = parseModule(sub, getModule(entry.Module))
+		case SstAlignSym:
+			if len(sub) < 4 {
+				break
+			}
+			// Skip the symbol table signature
+			getModule(entry.Module).Symbols, err = parseSymbols(sub[4:])
+		case SstSrcModule:
+			getModule(entry.Module).Sources, err = parseSourceModule(sub)
+		case SstGlobalSym:
+			info.Globals, err = parseHashedSymbols(sub)
+		case SstGlobalPub:
+			info.Publics, err = parseHashedSymbols(sub)
+		case SstStaticSym:
+			info.Statics, err = parseHashedSymbols(sub)
+		case SstGlobalTypes:
+			info.Types, err = parseGlobalTypes(sub)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("CodeView subsection %03x: %w", uint16(entry.Type), err)
+		}
+	}
+
+	for _, module := range modules {
+		info.Modules = append(info.Modules, *module)
+	}
+	sort.Slice(info.Modules, func(i, j int) bool {
+		return info.Modules[i].Index < info.Modules[j].Index
+	})
+
+	return info, nil
+}
+
+func pascalString(data []byte) (string, []byte, error) {
+	if len(data) < 1 || len(data) < 1 + int(data[0]) {
+		return "", nil, fmt.Errorf("Truncated name")
+	}
+
+	return string(data[1:][:data[0]]), data[1 + int(data[0]):], nil
+}
+
+func parseModule(data []byte, module *Module) error {
+	le := binary.LittleEndian
+
+	if len(data) < 8 {
+		return fmt.Errorf("Truncated module")
+	}
+	module.Library = le.Uint16(data[2:])
+	count := int(le.Uint16(data[4:]))
+	data = data[8:]
+	if len(data) < count * 12 {
+		return fmt.Errorf("Truncated module segments")
+	}
+
+	for i := 0; i < count; i++ {
+		module.Segments = append(module.Segments, ModuleSegment{
+			Segment: le.Uint16(data),
+			Offset:  le.Uint32(data[4:]),
+			Size:    le.Uint32(data[8:]),
+		})
+		data = data[12:]
+	}
+
+	name, _, err := pascalString(data)
+	module.Name = name
+	return err
+}
+
+// Global symbol subsections are prefixed with hash table sizes, the hashes follow the symbols
+func parseHashedSymbols(data []byte) ([]Symbol, error) {
+	if len(data) < 16 {
+		return nil, fmt.Errorf("Truncated symbol table header")
+	}
+
+	size := binary.LittleEndian.Uint32(data[4:])
+	if uint64(size) + 16 > uint64(len(data)) {
+		return nil, fmt.Errorf("Symbol table is out of bounds")
+	}
+
+	return parseSymbols(data[16:][:size])
+}
+
+func parseSymbols(data []byte) ([]Symbol, error) {
+	le := binary.LittleEndian
+
+	var symbols []Symbol
+	for len(data) >= 4 {
+		size := int(le.Uint16(data))
+		if size < 2 || len(data) < size + 2 {
+			return nil, fmt.Errorf("Invalid symbol record size: %d", size)
+		}
+		kind := SymbolType(le.Uint16(data[2:]))
+		record := data[4:][:size - 2]
+		data = data[size + 2:]
+
+		symbol := Symbol{
+			Type: kind,
+		}
+
+		var rest []byte
+		switch kind {
+		case SymbolPublic32, SymbolLocalData32, SymbolGlobalData32:
+			if len(record) < 8 {
+				return nil, fmt.Errorf("Truncated data symbol")
+			}
+			symbol.Address = Address{Offset: le.Uint32(record), Segment: le.Uint16(record[4:])}
+			symbol.TypeIndex = uint32(le.Uint16(record[6:]))
+			rest = record[8:]
+		case SymbolPublic32T, SymbolLocalData32T, SymbolGlobalData32T:
+			if len(record) < 10 {
+				return nil, fmt.Errorf("Truncated data symbol")
+			}
+			symbol.TypeIndex = le.Uint32(record)
+			symbol.Address = Address{Offset: le.Uint32(record[4:]), Segment: le.Uint16(record[8:])}
+			rest = record[10:]
+		case SymbolLocalProc32, SymbolGlobalProc32:
+			if len(record) < 35 {
+				return nil, fmt.Errorf("Truncated procedure symbol")
+			}
+			symbol.Size = le.Uint32(record[12:])
+			symbol.Address = Address{Offset: le.Uint32(record[24:]), Segment: le.Uint16(record[28:])}
+			symbol.TypeIndex = uint32(le.Uint16(record[30:]))
+			rest = record[33:]
+		case SymbolLocalProc32T, SymbolGlobalProc32T:
+			if len(record) < 37 {
+				return nil, fmt.Errorf("Truncated procedure symbol")
+			}
+			symbol.Size = le.Uint32(record[12:])
+			symbol.TypeIndex = le.Uint32(record[24:])
+			symbol.Address = Address{Offset: le.Uint32(record[28:]), Segment: le.Uint16(record[32:])}
+			rest = record[35:]
+		default:
+			// Scopes, locals, references and the like are not needed
+			continue
+		}
+
+		name, _, err := pascalString(rest)
+		if err != nil {
+			return nil, err
+		}
+		symbol.Name = name
+
+		symbols = append(symbols, symbol)
+	}
+
+	return symbols, nil
+}
+
+func parseSourceModule(data []byte) ([]SourceFile, error) {
+	le := binary.LittleEndian
+
+	if len(data) < 4 {
+		return nil, fmt.Errorf("Truncated source module")
+	}
+	fileCount := int(le.Uint16(data))
+	if len(data) < 4 + fileCount * 4 {
+		return nil, fmt.Errorf("Truncated source module")
+	}
+
+	var files []SourceFile
+	for i := 0; i < fileCount; i++ {
+		offset := le.Uint32(data[4 + i * 4:])
+		if uint64(offset) + 4 > uint64(len(data)) {
+			return nil, fmt.Errorf("Source file is out of bounds")
+		}
+		entry := data[offset:]
+
+		segCount := int(le.Uint16(entry))
+		if len(entry) < 4 + segCount * 12 {
+			return nil, fmt.Errorf("Truncated source file")
+		}
+
+		name, _, err := pascalString(entry[4 + segCount * 12:])
+		if err != nil {
+			return nil, err
+		}
+		file := SourceFile{
+			Name: name,
+		}
+
+		for j := 0; j < segCount; j++ {
+			lineOffset := le.Uint32(entry[4 + j * 4:])
+			sourceRange := SourceRange{
+				Start: le.Uint32(entry[4 + segCount * 4 + j * 8:]),
+				End:   le.Uint32(entry[4 + segCount * 4 + j * 8 + 4:]),
+			}
+
+			if uint64(lineOffset) + 4 > uint64(len(data)) {
+				return nil, fmt.Errorf("Line table is out of bounds")
+			}
+			lines := data[lineOffset:]
+			sourceRange.Segment = le.Uint16(lines)
+			pairs := int(le.Uint16(lines[2:]))
+			if len(lines) < 4 + pairs * 6 {
+				return nil, fmt.Errorf("Truncated line table")
+			}
+
+			for k := 0; k < pairs; k++ {
+				sourceRange.Lines = append(sourceRange.Lines, Line{
+					Offset: le.Uint32(lines[4 + k * 4:]),
+					Number: le.Uint16(lines[4 + pairs * 4 + k * 2:]),
+				})
+			}
+
+			file.Ranges = append(file.Ranges, sourceRange)
+		}
+
+		files = append(files, file)
+	}
+
+	return files, nil
+}
+
+// First non-primitive type index
+const FirstTypeIndex = 0x1000
+
+func parseGlobalTypes(data []byte) ([]TypeRecord, error) {
+	le := binary.LittleEndian
+
+	if len(data) < 8 {
+		return nil, fmt.Errorf("Truncated global types")
+	}
+	count := le.Uint32(data[4:])
+	if uint64(count) * 4 + 8 > uint64(len(data)) {
+		return nil, fmt.Errorf("Global types table is out of bounds")
+	}
+
+	// Offsets are relative to the end of the offset table
+	base := data[8 + count * 4:]
+
+	var types []TypeRecord
+	for i := uint32(0); i < count; i++ {
+		offset := le.Uint32(data[8 + i * 4:])
+		if uint64(offset) + 4 > uint64(len(base)) {
+			return nil, fmt.Errorf("Type %x is out of bounds", FirstTypeIndex + i)
+		}
+
+		size := int(le.Uint16(base[offset:]))
+		if size < 2 || uint64(offset) + 2 + uint64(size) > uint64(len(base)) {
+			return nil, fmt.Errorf("Type %x is out of bounds", FirstTypeIndex + i)
+		}
+
+		types = append(types, TypeRecord{
+			Index: FirstTypeIndex + i,
+			Leaf:  le.Uint16(base[offset + 2:]),
+			Data:  base[offset + 4:][:size - 2],
+		})
+	}
+
+	return types, nil
+}
+
+func (info *Info) TypeByIndex(index uint32) (TypeRecord, bool) {
+	if index < FirstTypeIndex || index - FirstTypeIndex >= uint32(len(info.Types)) {
+		return TypeRecord{}, false
+	}
+
+	return info.Types[index - FirstTypeIndex], true
+}
+
+var primitiveTypeNames = map[uint32]string{
+	0x0003: "void",
+	0x0010: "char",
+	0x0011: "short",
+	0x0012: "long",
+	0x0013: "long long",
+	0x0020: "unsigned char",
+	0x0021: "unsigned short",
+	0x0022: "unsigned long",
+	0x0023: "unsigned long long",
+	0x0030: "bool",
+	0x0040: "float",
+	0x0041: "double",
+	0x0042: "long double",
+	0x0068: "signed char",
+	0x0069: "unsigned char",
+	0x0070: "char",
+	0x0071: "wchar_t",
+	0x0074: "int",
+	0x0075: "unsigned int",
+}
+
+const (
+	leafPointer16t   = 0x0002
+	leafProcedure16t = 0x0008
+	leafPointer      = 0x1002
+	leafProcedure    = 0x1008
+)
+
+// Best-effort readable name of a type, good enough to compare against other debug formats
+func (info *Info) TypeName(index uint32) string {
+	return info.typeName(index, map[uint32]struct{}{})
+}
+
+// Types may refer back to themselves, those already being named are left as is
+func (info *Info) typeName(index uint32, visiting map[uint32]struct{}) string {
+	if index < FirstTypeIndex {
+		name, found := primitiveTypeNames[index & 0xff]
+		if !found {
+			return fmt.Sprintf("primitive(%04x)", index)
+		}
+		// Near and far 32-bit pointers to primitive types
+		if mode := (index >> 8) & 7; mode == 4 || mode == 5 {
+			return name + " *"
+		}
+		return name
+	}
+
+	record, found := info.TypeByIndex(index)
+	if _, cycle := visiting[index]; !found || cycle {
+		return fmt.Sprintf("type(%x)", index)
+	}
+	visiting[index] = struct{}{}
+	defer delete(visiting, index)
+
+	le := binary.LittleEndian
+	switch record.Leaf {
+	case leafPointer16t:
+		if len(record.Data) >= 4 {
+			return info.typeName(uint32(le.Uint16(record.Data[2:])), visiting) + " *"
+		}
+	case leafPointer:
+		if len(record.Data) >= 4 {
+			return info.typeName(le.Uint32(record.Data), visiting) + " *"
+		}
+	case leafProcedure16t:
+		if len(record.Data) >= 2 {
+			return info.typeName(uint32(le.Uint16(record.Data)), visiting) + " ()"
+		}
+	case leafProcedure:
+		if len(record.Data) >= 4 {
+			return info.typeName(le.Uint32(record.Data), visiting) + " ()"
+		}
+	}
+
+	return fmt.Sprintf("leaf(%04x)", record.Leaf)
+}
@@ -0,0 +1,147 @@
+package codeview
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+type subsection struct {
+	kind SubsectionType
+	data []byte
+}
+
+// Lays out NB09 info with a single directory holding the given subsections
+func buildInfo(subsections ...subsection) []byte {
+	le := binary.LittleEndian
+
+	var body bytes.Buffer
+	var entries bytes.Buffer
+	offset := uint32(8)
+	for _, sub := range subsections {
+		binary.Write(&entries, le, struct {
+			Type   uint16
+			Module uint16
+			Offset uint32
+			Size   uint32
+		}{uint16(sub.kind), 0, offset, uint32(len(sub.data))})
+		body.Write(sub.data)
+		offset += uint32(len(sub.data))
+	}
+
+	var b bytes.Buffer
+	b.WriteString("NB09")
+	binary.Write(&b, le, offset)
+	b.Write(body.Bytes())
+	binary.Write(&b, le, [2]uint16{16, 12})
+	binary.Write(&b, le, [3]uint32{uint32(len(subsections)), 0, 0})
+	b.Write(entries.Bytes())
+
+	return b.Bytes()
+}
+
+// Global types table of LF_POINTER records to the given types
+func pointerTypes(targets ...uint32) []byte {
+	le := binary.LittleEndian
+
+	var records bytes.Buffer
+	var offsets []uint32
+	for _, target := range targets {
+		offsets = append(offsets, uint32(records.Len()))
+		binary.Write(&records, le, [2]uint16{10, leafPointer})
+		binary.Write(&records, le, [2]uint32{target, 0})
+	}
+
+	var b bytes.Buffer
+	binary.Write(&b, le, [2]uint32{0, uint32(len(targets))})
+	binary.Write(&b, le, offsets)
+	b.Write(records.Bytes())
+	return b.Bytes()
+}
+
+func TestTypeName(t *testing.T) {
+	info, err := Parse(buildInfo(subsection{SstGlobalTypes, pointerTypes(
+		0x0074, // int *
+		0x1000, // int **
+		0x1002, // points to itself
+		0x1004, // part of a cycle of two
+		0x1003,
+	)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		index uint32
+		name  string
+	}{
+		{0x0074, "int"},
+		{0x0474, "int *"},
+		{0x1000, "int *"},
+		{0x1001, "int * *"},
+		{0x1002, "type(1002) *"},
+		{0x1003, "type(1003) * *"},
+		{0x1005, "type(1005)"},
+	}
+	for _, test := range tests {
+		if name := info.TypeName(test.index); name != test.name {
+			t.Errorf("Type %x is named %q, expected %q", test.index, name, test.name)
+		}
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	le := binary.LittleEndian
+
+	tests := []struct {
+		name   string
+		data   func() []byte
+		errors string
+	}{
+		{"unknown signature", func() []byte {
+			return []byte("NB05\x00\x00\x00\x00")
+		}, "signature"},
+		{"directory past the end", func() []byte {
+			data := buildInfo()
+			le.PutUint32(data[4:], 0xfffffff0)
+			return data
+		}, "out of bounds"},
+		{"directory entry count past the end", func() []byte {
+			data := buildInfo()
+			le.PutUint32(data[len(data) - 12:], 0x40000000)
+			return data
+		}, "out of bounds"},
+		{"directories forming a loop", func() []byte {
+			data := buildInfo()
+			// A second directory linking back to the first one
+			first := le.Uint32(data[4:])
+			second := uint32(len(data))
+			le.PutUint32(data[first + 8:], second)
+			data = le.AppendUint16(data, 16)
+			data = le.AppendUint16(data, 12)
+			data = le.AppendUint32(data, 0)
+			data = le.AppendUint32(data, first)
+			return le.AppendUint32(data, 0)
+		}, "loop"},
+		{"subsection past the end", func() []byte {
+			data := buildInfo(subsection{SstGlobalTypes, pointerTypes(0x74)})
+			le.PutUint32(data[len(data) - 4:], 0x10000)
+			return data
+		}, "out of bounds"},
+		{"type count past the end", func() []byte {
+			types := pointerTypes(0x74)
+			le.PutUint32(types[4:], 0x40000000)
+			return buildInfo(subsection{SstGlobalTypes, types})
+		}, "out of bounds"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse(test.data())
+			if err == nil || !strings.Contains(err.Error(), test.errors) {
+				t.Fatalf("Expected an error containing %q, got %v", test.errors, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package codeview
+
+import (
+	"fmt"
+)
+
+type SubsectionType uint16
+const (
+	SstModule      SubsectionType = 0x120
+	SstTypes       SubsectionType = 0x121
+	SstPublic      SubsectionType = 0x122
+	SstPublicSym   SubsectionType = 0x123
+	SstSymbols     SubsectionType = 0x124
+	SstAlignSym    SubsectionType = 0x125
+	SstSrcLnSeg    SubsectionType = 0x126
+	SstSrcModule   SubsectionType = 0x127
+	SstLibraries   SubsectionType = 0x128
+	SstGlobalSym   SubsectionType = 0x129
+	SstGlobalPub   SubsectionType = 0x12a
+	SstGlobalTypes SubsectionType = 0x12b
+	SstMPC         SubsectionType = 0x12c
+	SstSegMap      SubsectionType = 0x12d
+	SstSegName     SubsectionType = 0x12e
+	SstPreComp     SubsectionType = 0x12f
+	SstFileIndex   SubsectionType = 0x133
+	SstStaticSym   SubsectionType = 0x134
+)
+
+type DirectoryEntry struct {
+	Type   SubsectionType
+	Module uint16
+	Offset uint32
+	Size   uint32
+}
+
+type SymbolType uint16
+const (
+	SymbolLocalData32  SymbolType = 0x0201
+	SymbolGlobalData32 SymbolType = 0x0202
+	SymbolPublic32     SymbolType = 0x0203
+	SymbolLocalProc32  SymbolType = 0x0204
+	SymbolGlobalProc32 SymbolType = 0x0205
+	SymbolProcRef      SymbolType = 0x0400
+	SymbolDataRef      SymbolType = 0x0401
+
+	// Same as above, but with 32-bit type indices
+	SymbolLocalData32T  SymbolType = 0x1007
+	SymbolGlobalData32T SymbolType = 0x1008
+	SymbolPublic32T     SymbolType = 0x1009
+	SymbolLocalProc32T  SymbolType = 0x100a
+	SymbolGlobalProc32T SymbolType = 0x100b
+)
+
+func (t SymbolType) IsProcedure() bool {
+	switch t {
+	case SymbolLocalProc32, SymbolGlobalProc32, SymbolLocalProc32T, SymbolGlobalProc32T:
+		return true
+	default:
+		return false
+	}
+}
+
+func (t SymbolType) IsData() bool {
+	switch t {
+	case SymbolLocalData32, SymbolGlobalData32, SymbolLocalData32T, SymbolGlobalData32T:
+		return true
+	default:
+		return false
+	}
+}
+
+func (t SymbolType) IsPublic() bool {
+	return t == SymbolPublic32 || t == SymbolPublic32T
+}
+
+func (t SymbolType) IsLocal() bool {
+	return t == SymbolLocalData32 || t == SymbolLocalProc32 || t == SymbolLocalData32T || t == SymbolLocalProc32T
+}
+
+// Segmented address, the segment being the 1-based section number for PE images
+type Address struct {
+	Offset  uint32
+	Segment uint16
+}
+
+func (a Address) String() string {
+	return fmt.Sprintf("%04x:%08x", a.Segment, a.Offset)
+}
+
+type Symbol struct {
+	Type    SymbolType
+	Name    string
+	Address Address
+	// Procedures only
+	Size      uint32
+	TypeIndex uint32
+}
+
+type ModuleSegment struct {
+	Segment uint16
+	Offset  uint32
+	Size    uint32
+}
+
+type Module struct {
+	Index    uint16
+	Name     string
+	Library  uint16
+	Segments []ModuleSegment
+	Symbols  []Symbol
+	Sources  []SourceFile
+}
+
+type Line struct {
+	Number uint16
+	Offset uint32
+}
+
+type SourceRange struct {
+	Segment uint16
+	Start   uint32
+	End     uint32
+	Lines   []Line
+}
+
+type SourceFile struct {
+	Name   string
+	Ranges []SourceRange
+}
+
+type TypeRecord struct {
+	Index uint32
+	Leaf  uint16
+	Data  []byte
+}
+
+type Info struct {
+	Signature string
+	Directory []DirectoryEntry
+
+	Modules []Module
+	Globals []Symbol
+	Publics []Symbol
+	Statics []Symbol
+	Types   []TypeRecord
+}
@@ -0,0 +1,192 @@
+package exe
+
+import (
+	"fmt"
+	"strings"
+)
+
+type FileCharacteristics uint16
+const (
+	FileRelocsStripped       FileCharacteristics = 0x0001
+	FileExecutableImage      FileCharacteristics = 0x0002
+	FileLineNumsStripped     FileCharacteristics = 0x0004
+	FileLocalSymsStripped    FileCharacteristics = 0x0008
+	FileAggressiveWsTrim     FileCharacteristics = 0x0010
+	FileLargeAddressAware    FileCharacteristics = 0x0020
+	FileBytesReversedLo      FileCharacteristics = 0x0080
+	File32BitMachine         FileCharacteristics = 0x0100
+	FileDebugStripped        FileCharacteristics = 0x0200
+	FileRemovableRunFromSwap FileCharacteristics = 0x0400
+	FileNetRunFromSwap       FileCharacteristics = 0x0800
+	FileSystem               FileCharacteristics = 0x1000
+	FileDll                  FileCharacteristics = 0x2000
+	FileUpSystemOnly         FileCharacteristics = 0x4000
+	FileBytesReversedHi      FileCharacteristics = 0x8000
+)
+
+var fileCharacteristicNames = []struct {
+	flag FileCharacteristics
+	name string
+}{
+	{FileRelocsStripped, "RELOCS_STRIPPED"},
+	{FileExecutableImage, "EXECUTABLE_IMAGE"},
+	{FileLineNumsStripped, "LINE_NUMS_STRIPPED"},
+	{FileLocalSymsStripped, "LOCAL_SYMS_STRIPPED"},
+	{FileAggressiveWsTrim, "AGGRESSIVE_WS_TRIM"},
+	{FileLargeAddressAware, "LARGE_ADDRESS_AWARE"},
+	{FileBytesReversedLo, "BYTES_REVERSED_LO"},
+	{File32BitMachine, "32BIT_MACHINE"},
+	{FileDebugStripped, "DEBUG_STRIPPED"},
+	{FileRemovableRunFromSwap, "REMOVABLE_RUN_FROM_SWAP"},
+	{FileNetRunFromSwap, "NET_RUN_FROM_SWAP"},
+	{FileSystem, "SYSTEM"},
+	{FileDll, "DLL"},
+	{FileUpSystemOnly, "UP_SYSTEM_ONLY"},
+	{FileBytesReversedHi, "BYTES_REVERSED_HI"},
+}
+
+func (c FileCharacteristics) Has(flags FileCharacteristics) bool {
+	return c & flags == flags
+}
+
+func (c FileCharacteristics) IsExecutable() bool {
+	return c.Has(FileExecutableImage)
+}
+
+func (c FileCharacteristics) IsDll() bool {
+	return c.Has(FileDll)
+}
+
+func (c FileCharacteristics) String() string {
+	var parts []string
+	rest := c
+	for _, entry := range fileCharacteristicNames {
+		if c.Has(entry.flag) {
+			parts = append(parts, entry.name)
+			rest &^= entry.flag
+		}
+	}
+	if rest != 0 {
+		parts = append(parts, fmt.Sprintf("%04x", uint16(rest)))
+	}
+	return strings.Join(parts, "|")
+}
+
+type SectionCharacteristics uint32
+const (
+	SectionNoPad             SectionCharacteristics = 0x00000008
+	SectionCode              SectionCharacteristics = 0x00000020
+	SectionInitializedData   SectionCharacteristics = 0x00000040
+	SectionUninitializedData SectionCharacteristics = 0x00000080
+	SectionLinkInfo          SectionCharacteristics = 0x00000200
+	SectionLinkRemove        SectionCharacteristics = 0x00000800
+	SectionLinkComdat        SectionCharacteristics = 0x00001000
+	SectionGpRelative        SectionCharacteristics = 0x00008000
+	SectionAlignMask         SectionCharacteristics = 0x00f00000
+	SectionRelocsOverflow    SectionCharacteristics = 0x01000000
+	SectionDiscardable       SectionCharacteristics = 0x02000000
+	SectionNotCached         SectionCharacteristics = 0x04000000
+	SectionNotPaged          SectionCharacteristics = 0x08000000
+	SectionShared            SectionCharacteristics = 0x10000000
+	SectionExecute           SectionCharacteristics = 0x20000000
+	SectionRead              SectionCharacteristics = 0x40000000
+	SectionWrite             SectionCharacteristics = 0x80000000
+)
+
+var sectionCharacteristicNames = []struct {
+	flag SectionCharacteristics
+	name string
+}{
+	{SectionNoPad, "NO_PAD"},
+	{SectionCode, "CODE"},
+	{SectionInitializedData, "INITIALIZED_DATA"},
+	{SectionUninitializedData, "UNINITIALIZED_DATA"},
+	{SectionLinkInfo, "LNK_INFO"},
+	{SectionLinkRemove, "LNK_REMOVE"},
+	{SectionLinkComdat, "LNK_COMDAT"},
+	{SectionGpRelative, "GPREL"},
+	{SectionRelocsOverflow, "LNK_NRELOC_OVFL"},
+	{SectionDiscardable, "DISCARDABLE"},
+	{SectionNotCached, "NOT_CACHED"},
+	{SectionNotPaged, "NOT_PAGED"},
+	{SectionShared, "SHARED"},
+	{SectionExecute, "EXECUTE"},
+	{SectionRead, "READ"},
+	{SectionWrite, "WRITE"},
+}
+
+func (c SectionCharacteristics) Has(flags SectionCharacteristics) bool {
+	return c & flags == flags
+}
+
+// Section contains executable code, either by content type or by memory protection
+func (c SectionCharacteristics) IsCode() bool {
+	return c.Has(SectionCode) || c.Has(SectionExecute)
+}
+
+func (c SectionCharacteristics) IsExecutable() bool {
+	return c.Has(SectionExecute)
+}
+
+func (c SectionCharacteristics) IsReadable() bool {
+	return c.Has(SectionRead)
+}
+
+func (c SectionCharacteristics) IsWritable() bool {
+	return c.Has(SectionWrite)
+}
+
+func (c SectionCharacteristics) IsInitializedData() bool {
+	return c.Has(SectionInitializedData)
+}
+
+// Section has no file backing and is zero-filled when loaded
+func (c SectionCharacteristics) IsUninitializedData() bool {
+	return c.Has(SectionUninitializedData) && !c.Has(SectionInitializedData) && !c.Has(SectionCode)
+}
+
+func (c SectionCharacteristics) IsDiscardable() bool {
+	return c.Has(SectionDiscardable)
+}
+
+// Alignment is only meaningful in object files, images usually leave it as zero
+func (c SectionCharacteristics) Alignment() uint32 {
+	bits := uint32(c & SectionAlignMask) >> 20
+	if bits == 0 {
+		return 0
+	}
+	return 1 << (bits - 1)
+}
+
+func (c SectionCharacteristics) String() string {
+	var parts []string
+	rest := c &^ SectionAlignMask
+	for _, entry := range sectionCharacteristicNames {
+		if c.Has(entry.flag) {
+			parts = append(parts, entry.name)
+			rest &^= entry.flag
+		}
+	}
+	if align := c.Alignment(); align != 0 {
+		parts = append(parts, fmt.Sprintf("ALIGN_%d", align))
+	}
+	if rest != 0 {
+		parts = append(parts, fmt.Sprintf("%08x", uint32(rest)))
+	}
+	return strings.Join(parts, "|")
+}
+
+// Short memory protection summary, in the style of "r-x"
+func (c SectionCharacteristics) Protection() string {
+	prot := []byte("---")
+	if c.IsReadable() {
+		prot[0] = 'r'
+	}
+	if c.IsWritable() {
+		prot[1] = 'w'
+	}
+	if c.IsExecutable() {
+		prot[2] = 'x'
+	}
+	return string(prot)
+}
@@ -0,0 +1,270 @@
+package exe
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/dexter3k/watre/explore/ext/codeview"
+)
+
+// Format-independent view of debug info. All addresses are VAs.
+
+type SymbolKind int
+const (
+	SymbolFunction SymbolKind = iota
+	SymbolVariable
+	SymbolPublic
+)
+
+func (k SymbolKind) String() string {
+	switch k {
+	case SymbolFunction:
+		return "function"
+	case SymbolVariable:
+		return "variable"
+	case SymbolPublic:
+		return "public"
+	default:
+		return fmt.Sprintf("SymbolKind(%d)", int(k))
+	}
+}
+
+type Symbol struct {
+	Name    string
+	Kind    SymbolKind
+	Address uint32
+	// Zero when the format does not record it
+	Size   uint32
+	Type   string
+	Module string
+	Local  bool
+}
+
+type Line struct {
+	Address uint32
+	File    string
+	Line    int
+}
+
+// Finds the line covering the address in a table sorted by address
+func LineAt(lines []Line, address uint32) (Line, bool) {
+	i := sort.Search(len(lines), func(i int) bool {
+		return lines[i].Address > address
+	})
+	if i == 0 {
+		return Line{}, false
+	}
+
+	return lines[i - 1], true
+}
+
+func sortSymbols(symbols []Symbol) {
+	sort.SliceStable(symbols, func(i, j int) bool {
+		if symbols[i].Address != symbols[j].Address {
+			return symbols[i].Address < symbols[j].Address
+		}
+		return symbols[i].Name < symbols[j].Name
+	})
+}
+
+func (f *File) codeViewSymbols(module string, source []codeview.Symbol) []Symbol {
+	var symbols []Symbol
+	for _, sym := range source {
+		address, ok := f.SegmentAddress(sym.Address.Segment, sym.Address.Offset)
+		if !ok {
+			continue
+		}
+
+		kind := SymbolPublic
+		if sym.Type.IsProcedure() {
+			kind = SymbolFunction
+		} else if sym.Type.IsData() {
+			kind = SymbolVariable
+		}
+
+		typeName := ""
+		if sym.TypeIndex != 0 {
+			typeName = f.CodeView.TypeName(sym.TypeIndex)
+		}
+
+		symbols = append(symbols, Symbol{
+			Name:    sym.Name,
+			Kind:    kind,
+			Address: address,
+			Size:    sym.Size,
+			Type:    typeName,
+			Module:  module,
+			Local:   sym.Type.IsLocal(),
+		})
+	}
+
+	return symbols
+}
+
+// Lists functions, variables and publics. Publics duplicating
+// a function or a variable at the same address are dropped.
+func (f *File) CodeViewSymbols() []Symbol {
+	if f.CodeView == nil {
+		return nil
+	}
+
+	var symbols []Symbol
+	for _, module := range f.CodeView.Modules {
+		symbols = append(symbols, f.codeViewSymbols(module.Name, module.Symbols)...)
+	}
+	symbols = append(symbols, f.codeViewSymbols("", f.CodeView.Globals)...)
+	symbols = append(symbols, f.codeViewSymbols("", f.CodeView.Statics)...)
+
+	type key struct {
+		name    string
+		address uint32
+	}
+	known := map[key]struct{}{}
+	for _, sym := range symbols {
+		known[key{sym.Name, sym.Address}] = struct{}{}
+	}
+	for _, sym := range f.codeViewSymbols("", f.CodeView.Publics) {
+		if _, found := known[key{sym.Name, sym.Address}]; !found {
+			symbols = append(symbols, sym)
+		}
+	}
+
+	sortSymbols(symbols)
+	return symbols
+}
+
+// Line table sorted by address, suitable for LineAt
+func (f *File) CodeViewLines() []Line {
+	if f.CodeView == nil {
+		return nil
+	}
+
+	var lines []Line
+	for _, module := range f.CodeView.Modules {
+		for _, source := range module.Sources {
+			for _, sourceRange := range source.Ranges {
+				for _, line := range sourceRange.Lines {
+					address, ok := f.SegmentAddress(sourceRange.Segment, line.Offset)
+					if !ok {
+						continue
+					}
+
+					lines = append(lines, Line{
+						Address: address,
+						File:    source.Name,
+						Line:    int(line.Number),
+					})
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lines[i].Address < lines[j].Address
+	})
+	return lines
+}
+
+// Lists the globals of all sections. Watcom does not record sizes or types.
+func (f *File) WatcomSymbols() []Symbol {
+	if f.Watcom == nil {
+		return nil
+	}
+
+	var symbols []Symbol
+	for _, section := range f.Watcom.Sections {
+		for _, global := range section.Globals {
+			address, ok := f.WatcomAddress(global.Address)
+			if !ok {
+				continue
+			}
+
+			kind := SymbolPublic
+			if global.Kind.IsCode() {
+				kind = SymbolFunction
+			} else if global.Kind.IsData() {
+				kind = SymbolVariable
+			}
+
+			module := ""
+			if int(global.Module) < len(section.Modules) {
+				module = section.Modules[global.Module].Name
+			}
+
+			symbols = append(symbols, Symbol{
+				Name:    global.Name,
+				Kind:    kind,
+				Address: address,
+				Module:  module,
+				Local:   global.Kind.IsStatic(),
+			})
+		}
+	}
+
+	sortSymbols(symbols)
+	return symbols
+}
+
+// Line table sorted by address, suitable for LineAt. Watcom only records
+// module names, so they stand in for the source files.
+func (f *File) WatcomLines() ([]Line, error) {
+	if f.Watcom == nil {
+		return nil, nil
+	}
+
+	var lines []Line
+	for _, section := range f.Watcom.Sections {
+		for _, module := range section.Modules {
+			blocks, err := module.LineBlocks()
+			if err != nil {
+				return nil, err
+			}
+
+			for _, block := range blocks {
+				if block.Segment > 0xffff {
+					continue
+				}
+				for _, line := range block.Lines {
+					address, ok := f.SegmentAddress(uint16(block.Segment), line.Offset)
+					if !ok {
+						continue
+					}
+
+					lines = append(lines, Line{
+						Address: address,
+						File:    module.Name,
+						Line:    int(line.Number),
+					})
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lines[i].Address < lines[j].Address
+	})
+	return lines, nil
+}
+
+// Symbols from whichever debug info the file carries, DWARF first, then Watcom
+func (f *File) Symbols() ([]Symbol, error) {
+	if f.Dwarf != nil {
+		return f.DwarfSymbols()
+	}
+	if f.Watcom != nil {
+		return f.WatcomSymbols(), nil
+	}
+
+	return f.CodeViewSymbols(), nil
+}
+
+func (f *File) Lines() ([]Line, error) {
+	if f.Dwarf != nil {
+		return f.DwarfLines()
+	}
+	if f.Watcom != nil {
+		return f.WatcomLines()
+	}
+
+	return f.CodeViewLines(), nil
+}
@@ -0,0 +1,44 @@
+package exe
+
+import (
+	"slices"
+	"testing"
+)
+
+// Watcom debug info of one module, main.c, with _main at 1:10 and line 10 at 1:0
+const watcomFixture = "testdata/watcom-debug-info"
+
+func TestWatcomDebugInfo(t *testing.T) {
+	file, err := Parse(mustRead(t, fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The COFF symbol table overlay is replaced with the Watcom debug info
+	data := append(slices.Clone(file.source[:file.OverlayOffset]), mustRead(t, watcomFixture)...)
+
+	file, err = Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.LoadDebugInfo()
+	if file.Watcom == nil || len(file.DebugErrors) != 0 {
+		t.Fatalf("Watcom debug info is not loaded: %v", file.DebugErrors)
+	}
+
+	symbols, err := file.Symbols()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Symbol{{Name: "_main", Kind: SymbolFunction, Address: 0x401010, Module: "main.c"}}
+	if !slices.Equal(symbols, expected) {
+		t.Errorf("Symbols are %+v", symbols)
+	}
+
+	lines, err := file.Lines()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line, found := LineAt(lines, 0x401010); len(lines) != 1 || !found || line != (Line{0x401000, "main.c", 10}) {
+		t.Errorf("Lines are %+v", lines)
+	}
+}
@@ -0,0 +1,399 @@
+package exe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"unicode/utf16"
+)
+
+type DebugType uint32
+const (
+	DebugUnknown   DebugType = 0
+	DebugCoff      DebugType = 1
+	DebugCodeView  DebugType = 2
+	DebugFpo       DebugType = 3
+	DebugMisc      DebugType = 4
+	DebugException DebugType = 5
+	DebugFixup     DebugType = 6
+	DebugBorland   DebugType = 9
+)
+
+func (t DebugType) String() string {
+	switch t {
+	case DebugUnknown:
+		return "UNKNOWN"
+	case DebugCoff:
+		return "COFF"
+	case DebugCodeView:
+		return "CODEVIEW"
+	case DebugFpo:
+		return "FPO"
+	case DebugMisc:
+		return "MISC"
+	case DebugException:
+		return "EXCEPTION"
+	case DebugFixup:
+		return "FIXUP"
+	case DebugBorland:
+		return "BORLAND"
+	default:
+		return fmt.Sprintf("DebugType(%d)", uint32(t))
+	}
+}
+
+type DebugDirectoryEntry struct {
+	Characteristics uint32
+	TimeDateStamp   uint32
+	MajorVersion    uint16
+	MinorVersion    uint16
+	Type            DebugType
+	SizeOfData      uint32
+	// RVA, zero when the data is not mapped
+	AddressOfRawData uint32
+	PointerToRawData uint32
+}
+
+const debugDirectoryEntrySize = 28
+
+// All addresses are VAs
+type TlsDirectory struct {
+	StartAddressOfRawData uint32
+	EndAddressOfRawData   uint32
+	AddressOfIndex        uint32
+	AddressOfCallBacks    uint32
+	SizeOfZeroFill        uint32
+	Characteristics       uint32
+
+	Callbacks []uint32
+}
+
+// 32-bit load configuration. Fields past Size are left zeroed, since
+// older linkers write shorter structures.
+type LoadConfig struct {
+	Size                           uint32
+	TimeDateStamp                  uint32
+	MajorVersion                   uint16
+	MinorVersion                   uint16
+	GlobalFlagsClear               uint32
+	GlobalFlagsSet                 uint32
+	CriticalSectionDefaultTimeout  uint32
+	DeCommitFreeBlockThreshold     uint32
+	DeCommitTotalFreeThreshold     uint32
+	LockPrefixTable                uint32
+	MaximumAllocationSize          uint32
+	VirtualMemoryThreshold         uint32
+	ProcessHeapFlags               uint32
+	ProcessAffinityMask            uint32
+	CSDVersion                     uint16
+	DependentLoadFlags             uint16
+	EditList                       uint32
+	SecurityCookie                 uint32
+	SEHandlerTable                 uint32
+	SEHandlerCount                 uint32
+	GuardCFCheckFunctionPointer    uint32
+	GuardCFDispatchFunctionPointer uint32
+	GuardCFFunctionTable           uint32
+	GuardCFFunctionCount           uint32
+	GuardFlags                     uint32
+}
+
+// Returns the bytes of a data directory, nil if the file has no such directory
+func (f *File) directoryData(index int) ([]byte, error) {
+	directory, found := f.GetDataDirectory(index)
+	if !found {
+		return nil, nil
+	}
+
+	section, offset := f.SectionByRVA(directory.VirtualAddress)
+	if section == nil || uint64(offset) + uint64(directory.Size) > uint64(len(section.Raw)) {
+		return nil, fmt.Errorf("Data directory %d is out of bounds", index)
+	}
+
+	return section.Raw[offset:][:directory.Size], nil
+}
+
+// Returns file contents at the given offset, be it section data or the overlay
+func (f *File) fileBytes(offset, size uint32) ([]byte, bool) {
+	for _, entry := range f.Sections {
+		if !entry.HasFileData() || offset < entry.RawOffset {
+			continue
+		}
+		start := uint64(offset - entry.RawOffset)
+		if start + uint64(size) <= uint64(len(entry.Raw)) {
+			return entry.Raw[start:][:size], true
+		}
+	}
+
+	if len(f.Overlay) > 0 && int64(offset) >= f.OverlayOffset {
+		start := uint64(int64(offset) - f.OverlayOffset)
+		if start + uint64(size) <= uint64(len(f.Overlay)) {
+			return f.Overlay[start:][:size], true
+		}
+	}
+
+	return nil, false
+}
+
+func (f *File) DebugDirectory() ([]DebugDirectoryEntry, error) {
+	data, err := f.directoryData(DirectoryDebug)
+	if err != nil || data == nil {
+		return nil, err
+	}
+
+	entries := make([]DebugDirectoryEntry, len(data) / debugDirectoryEntrySize)
+	if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, entries); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// Data the debug directory entry points to, looked up by RVA when mapped and by file offset otherwise
+func (f *File) DebugData(entry DebugDirectoryEntry) ([]byte, error) {
+	if entry.AddressOfRawData != 0 {
+		section, offset := f.SectionByRVA(entry.AddressOfRawData)
+		if section != nil && uint64(offset) + uint64(entry.SizeOfData) <= uint64(len(section.Raw)) {
+			return section.Raw[offset:][:entry.SizeOfData], nil
+		}
+	}
+
+	data, found := f.fileBytes(entry.PointerToRawData, entry.SizeOfData)
+	if !found {
+		return nil, fmt.Errorf("%s debug data at %08x+%x is out of bounds", entry.Type, entry.PointerToRawData, entry.SizeOfData)
+	}
+
+	return data, nil
+}
+
+func cstring(data []byte) string {
+	if idx := bytes.IndexByte(data, 0); idx != -1 {
+		return string(data[:idx])
+	}
+	return string(data)
+}
+
+// Path referenced by a CodeView PDB record (NB10 or RSDS) or a MISC record
+func (f *File) DebugPath(entry DebugDirectoryEntry) (string, bool) {
+	data, err := f.DebugData(entry)
+	if err != nil {
+		return "", false
+	}
+
+	switch entry.Type {
+	case DebugCodeView:
+		switch {
+		case len(data) > 16 && string(data[:4]) == "NB10":
+			return cstring(data[16:]), true
+		case len(data) > 24 && string(data[:4]) == "RSDS":
+			return cstring(data[24:]), true
+		}
+	case DebugMisc:
+		// Only the executable name record is of interest
+		if len(data) < 12 || binary.LittleEndian.Uint32(data) != 1 {
+			break
+		}
+		if data[8] == 0 {
+			return cstring(data[12:]), true
+		}
+
+		units := make([]uint16, (len(data) - 12) / 2)
+		for i := range units {
+			units[i] = binary.LittleEndian.Uint16(data[12 + i * 2:])
+		}
+		for i, unit := range units {
+			if unit == 0 {
+				units = units[:i]
+				break
+			}
+		}
+		return string(utf16.Decode(units)), true
+	}
+
+	return "", false
+}
+
+func (f *File) TlsDirectory() (*TlsDirectory, error) {
+	data, err := f.directoryData(DirectoryTls)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	if len(data) < 24 {
+		return nil, fmt.Errorf("TLS directory is too small: %d", len(data))
+	}
+
+	le := binary.LittleEndian
+	tls := &TlsDirectory{
+		StartAddressOfRawData: le.Uint32(data),
+		EndAddressOfRawData:   le.Uint32(data[4:]),
+		AddressOfIndex:        le.Uint32(data[8:]),
+		AddressOfCallBacks:    le.Uint32(data[12:]),
+		SizeOfZeroFill:        le.Uint32(data[16:]),
+		Characteristics:       le.Uint32(data[20:]),
+	}
+
+	// Callbacks are a zero-terminated array of VAs
+	if tls.AddressOfCallBacks != 0 {
+		img := NewImage(f)
+		for address := tls.AddressOfCallBacks; ; address += 4 {
+			callback, err := img.Bytes(address, 4)
+			if err != nil {
+				return nil, fmt.Errorf("TLS callbacks: %w", err)
+			}
+			if le.Uint32(callback) == 0 {
+				break
+			}
+			tls.Callbacks = append(tls.Callbacks, le.Uint32(callback))
+		}
+	}
+
+	return tls, nil
+}
+
+func (f *File) LoadConfig() (*LoadConfig, error) {
+	data, err := f.directoryData(DirectoryLoadConfig)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	if len(data) < 4 {
+		return nil, fmt.Errorf("Load config is too small: %d", len(data))
+	}
+
+	// The structure declares its own size, which may differ from the directory size
+	size := min(int(binary.LittleEndian.Uint32(data)), len(data))
+	padded := make([]byte, binary.Size(LoadConfig{}))
+	copy(padded, data[:size])
+
+	config := &LoadConfig{}
+	if err := binary.Read(bytes.NewReader(padded), binary.LittleEndian, config); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// Handler count comes straight from the file, the table must still fit into 32 bits
+func (c *LoadConfig) seHandlerTableSize() (uint32, error) {
+	size := uint64(c.SEHandlerCount) * 4
+	if size > math.MaxUint32 {
+		return 0, fmt.Errorf("SEH handler table is too large: %d handlers", c.SEHandlerCount)
+	}
+
+	return uint32(size), nil
+}
+
+// Handlers registered for SafeSEH, as VAs
+func (f *File) SafeSehHandlers(config *LoadConfig) ([]uint32, error) {
+	if config.SEHandlerTable == 0 || config.SEHandlerCount == 0 {
+		return nil, nil
+	}
+
+	size, err := config.seHandlerTableSize()
+	if err != nil {
+		return nil, err
+	}
+	// Check against the section before allocating anything of that size
+	section, offset := f.SectionByRVA(config.SEHandlerTable - f.Windows.ImageBase)
+	if section == nil || uint64(offset) + uint64(size) > uint64(section.MappedSize()) {
+		return nil, fmt.Errorf("SEH handler table is out of bounds: %08x+%x", config.SEHandlerTable, size)
+	}
+	table, err := NewImage(f).Bytes(config.SEHandlerTable, size)
+	if err != nil {
+		return nil, fmt.Errorf("SEH handler table: %w", err)
+	}
+
+	handlers := make([]uint32, len(table) / 4)
+	for i := range handlers {
+		handlers[i] = f.Windows.ImageBase + binary.LittleEndian.Uint32(table[i * 4:])
+	}
+
+	return handlers, nil
+}
+
+// Named addresses known from the headers alone, to seed matching and function
+// discovery. Malformed directories are skipped, the first error is returned
+// along with whatever was collected.
+func (f *File) SeedSymbols() ([]Symbol, error) {
+	var symbols []Symbol
+	var firstErr error
+	fail := func(err error) {
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	add := func(name string, kind SymbolKind, address, size uint32) {
+		symbols = append(symbols, Symbol{
+			Name:    name,
+			Kind:    kind,
+			Address: address,
+			Size:    size,
+		})
+	}
+
+	if f.Standard.EntryPoints != 0 {
+		add("start", SymbolFunction, f.Windows.ImageBase + f.Standard.EntryPoints, 0)
+	}
+
+	if tls, err := f.TlsDirectory(); err != nil {
+		fail(err)
+	} else if tls != nil {
+		for i, callback := range tls.Callbacks {
+			add(fmt.Sprintf("TlsCallback_%d", i), SymbolFunction, callback, 0)
+		}
+		if tls.AddressOfIndex != 0 {
+			add("_tls_index", SymbolVariable, tls.AddressOfIndex, 4)
+		}
+		if tls.StartAddressOfRawData != 0 && tls.EndAddressOfRawData >= tls.StartAddressOfRawData {
+			add("_tls_start", SymbolVariable, tls.StartAddressOfRawData, tls.EndAddressOfRawData - tls.StartAddressOfRawData)
+		}
+	}
+
+	if config, err := f.LoadConfig(); err != nil {
+		fail(err)
+	} else if config != nil {
+		if config.SecurityCookie != 0 {
+			add("__security_cookie", SymbolVariable, config.SecurityCookie, 4)
+		}
+		if config.LockPrefixTable != 0 {
+			add("__lock_prefix_table", SymbolVariable, config.LockPrefixTable, 0)
+		}
+		if config.SEHandlerTable != 0 {
+			// An oversized table is reported by SafeSehHandlers below
+			size, _ := config.seHandlerTableSize()
+			add("__safe_se_handler_table", SymbolVariable, config.SEHandlerTable, size)
+		}
+
+		handlers, err := f.SafeSehHandlers(config)
+		if err != nil {
+			fail(err)
+		}
+		for i, handler := range handlers {
+			add(fmt.Sprintf("SEHandler_%d", i), SymbolFunction, handler, 0)
+		}
+	}
+
+	entries, err := f.DebugDirectory()
+	if err != nil {
+		fail(err)
+	}
+	for i, entry := range entries {
+		if entry.AddressOfRawData == 0 {
+			continue
+		}
+
+		address := f.Windows.ImageBase + entry.AddressOfRawData
+		add(fmt.Sprintf("DebugData_%d_%s", i, entry.Type), SymbolVariable, address, entry.SizeOfData)
+
+		// Point at the path string itself, so it can be named and typed as such
+		if path, found := f.DebugPath(entry); found {
+			data, _ := f.DebugData(entry)
+			if at := bytes.Index(data, []byte(path)); at != -1 && path != "" {
+				add(fmt.Sprintf("DebugPath_%d", i), SymbolVariable, address + uint32(at), uint32(len(path)) + 1)
+			}
+		}
+	}
+
+	sortSymbols(symbols)
+	return symbols, firstErr
+}
@@ -0,0 +1,57 @@
+package exe
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+// A file with two SafeSEH handlers in a single page sized section
+func sehFile() *File {
+	raw := make([]byte, 0x1000)
+	binary.LittleEndian.PutUint32(raw[0x10:], 0x1100)
+	binary.LittleEndian.PutUint32(raw[0x14:], 0x1200)
+
+	return &File{
+		Windows: PeWindowsFields{ImageBase: 0x400000},
+		Sections: []SectionEntry{{
+			Name:           ".rdata",
+			VirtualAddress: 0x1000,
+			VirtualSize:    0x1000,
+			RawOffset:      0x400,
+			RawSize:        0x1000,
+			Raw:            raw,
+		}},
+	}
+}
+
+func TestSafeSehHandlers(t *testing.T) {
+	handlers, err := sehFile().SafeSehHandlers(&LoadConfig{SEHandlerTable: 0x401010, SEHandlerCount: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(handlers) != 2 || handlers[0] != 0x401100 || handlers[1] != 0x401200 {
+		t.Errorf("Handlers are %x", handlers)
+	}
+}
+
+func TestSafeSehHandlersMalformed(t *testing.T) {
+	tests := []struct {
+		name   string
+		config LoadConfig
+		errors string
+	}{
+		{"count overflowing 32 bits", LoadConfig{SEHandlerTable: 0x401010, SEHandlerCount: 0x40000001}, "too large"},
+		{"count past the section", LoadConfig{SEHandlerTable: 0x401010, SEHandlerCount: 0x3fffffff}, "out of bounds"},
+		{"table outside of all sections", LoadConfig{SEHandlerTable: 0x500000, SEHandlerCount: 1}, "out of bounds"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := sehFile().SafeSehHandlers(&test.config)
+			if err == nil || !strings.Contains(err.Error(), test.errors) {
+				t.Fatalf("Expected an error containing %q, got %v", test.errors, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package exe
+
+import (
+	"debug/dwarf"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sort"
+)
+
+type CompileUnit struct {
+	Name      string
+	Directory string
+	Producer  string
+	Language  int64
+	// Zero when the unit does not record a single contiguous range
+	LowPC  uint32
+	HighPC uint32
+}
+
+const dwOpAddr = 0x03
+
+// High PC is either an address or, since DWARF 4, an offset from the low PC
+func dwarfRange(entry *dwarf.Entry) (uint32, uint32, bool) {
+	low, ok := entry.Val(dwarf.AttrLowpc).(uint64)
+	if !ok {
+		return 0, 0, false
+	}
+
+	field := entry.AttrField(dwarf.AttrHighpc)
+	if field == nil {
+		return 0, 0, false
+	}
+
+	switch high := field.Val.(type) {
+	case uint64:
+		return uint32(low), uint32(high), true
+	case int64:
+		return uint32(low), uint32(low + uint64(high)), true
+	default:
+		return 0, 0, false
+	}
+}
+
+// Only plain DW_OP_addr locations are static addresses
+func dwarfStaticAddress(entry *dwarf.Entry) (uint32, bool) {
+	location, ok := entry.Val(dwarf.AttrLocation).([]byte)
+	if !ok || len(location) == 0 || location[0] != dwOpAddr {
+		return 0, false
+	}
+
+	switch len(location) {
+	case 5:
+		return binary.LittleEndian.Uint32(location[1:]), true
+	case 9:
+		address := binary.LittleEndian.Uint64(location[1:])
+		return uint32(address), address <= 0xffffffff
+	default:
+		return 0, false
+	}
+}
+
+func (f *File) dwarfTypeName(entry *dwarf.Entry) string {
+	offset, ok := entry.Val(dwarf.AttrType).(dwarf.Offset)
+	if !ok {
+		return ""
+	}
+
+	typ, err := f.Dwarf.Type(offset)
+	if err != nil {
+		return ""
+	}
+
+	return typ.String()
+}
+
+func (f *File) DwarfCompileUnits() ([]CompileUnit, error) {
+	if f.Dwarf == nil {
+		return nil, nil
+	}
+
+	var units []CompileUnit
+	r := f.Dwarf.Reader()
+	for {
+		entry, err := r.Next()
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			break
+		}
+		if entry.Tag != dwarf.TagCompileUnit {
+			r.SkipChildren()
+			continue
+		}
+
+		unit := CompileUnit{}
+		unit.Name, _ = entry.Val(dwarf.AttrName).(string)
+		unit.Directory, _ = entry.Val(dwarf.AttrCompDir).(string)
+		unit.Producer, _ = entry.Val(dwarf.AttrProducer).(string)
+		unit.Language, _ = entry.Val(dwarf.AttrLanguage).(int64)
+		if low, high, ok := dwarfRange(entry); ok {
+			unit.LowPC, unit.HighPC = low, high
+		}
+		units = append(units, unit)
+
+		r.SkipChildren()
+	}
+
+	return units, nil
+}
+
+// Lists functions and statically allocated variables. Functions
+// span Address to Address+Size, the size being high PC minus low PC.
+func (f *File) DwarfSymbols() ([]Symbol, error) {
+	if f.Dwarf == nil {
+		return nil, nil
+	}
+
+	var symbols []Symbol
+	module := ""
+	r := f.Dwarf.Reader()
+	for {
+		entry, err := r.Next()
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			break
+		}
+
+		switch entry.Tag {
+		case dwarf.TagCompileUnit:
+			module, _ = entry.Val(dwarf.AttrName).(string)
+		case dwarf.TagSubprogram:
+			low, high, ok := dwarfRange(entry)
+			if !ok {
+				continue
+			}
+			name, _ := entry.Val(dwarf.AttrName).(string)
+			external, _ := entry.Val(dwarf.AttrExternal).(bool)
+			symbols = append(symbols, Symbol{
+				Name:    name,
+				Kind:    SymbolFunction,
+				Address: low,
+				Size:    high - low,
+				Type:    f.dwarfTypeName(entry),
+				Module:  module,
+				Local:   !external,
+			})
+		case dwarf.TagVariable:
+			address, ok := dwarfStaticAddress(entry)
+			if !ok {
+				continue
+			}
+			name, _ := entry.Val(dwarf.AttrName).(string)
+			external, _ := entry.Val(dwarf.AttrExternal).(bool)
+			symbol := Symbol{
+				Name:    name,
+				Kind:    SymbolVariable,
+				Address: address,
+				Type:    f.dwarfTypeName(entry),
+				Module:  module,
+				Local:   !external,
+			}
+			if offset, ok := entry.Val(dwarf.AttrType).(dwarf.Offset); ok {
+				if typ, err := f.Dwarf.Type(offset); err == nil && typ.Size() > 0 {
+					symbol.Size = uint32(typ.Size())
+				}
+			}
+			symbols = append(symbols, symbol)
+		}
+	}
+
+	sortSymbols(symbols)
+	return symbols, nil
+}
+
+// Line table sorted by address, suitable for LineAt. End-of-sequence rows are dropped.
+func (f *File) DwarfLines() ([]Line, error) {
+	if f.Dwarf == nil {
+		return nil, nil
+	}
+
+	var lines []Line
+	r := f.Dwarf.Reader()
+	for {
+		entry, err := r.Next()
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			break
+		}
+		if entry.Tag != dwarf.TagCompileUnit {
+			r.SkipChildren()
+			continue
+		}
+
+		lr, err := f.Dwarf.LineReader(entry)
+		if err != nil {
+			return nil, err
+		}
+		r.SkipChildren()
+		if lr == nil {
+			continue
+		}
+
+		var row dwarf.LineEntry
+		for {
+			if err := lr.Next(&row); err != nil {
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				return nil, err
+			}
+			if row.EndSequence || row.File == nil {
+				continue
+			}
+
+			lines = append(lines, Line{
+				Address: uint32(row.Address),
+				File:    row.File.Name,
+				Line:    row.Line,
+			})
+		}
+	}
+
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lines[i].Address < lines[j].Address
+	})
+	return lines, nil
+}
@@ -0,0 +1,191 @@
+package exe
+
+import (
+	"fmt"
+	"io"
+)
+
+// Image is an executable mapped into its virtual address space.
+// All addresses taken and returned by Image are virtual addresses (VA),
+// unless explicitly named as RVA or file offset.
+type Image struct {
+	Base     uint32
+	Sections []ImageSection
+}
+
+type ImageSection struct {
+	Name            string
+	Address         uint32
+	Size            uint32
+	Characteristics SectionCharacteristics
+
+	// File-backed part of the section. It may be shorter than Size,
+	// the rest of the section is zero-filled when loaded.
+	Offset uint32
+	Data   []byte
+}
+
+func NewImage(f *File) *Image {
+	img := &Image{
+		Base: f.Windows.ImageBase,
+	}
+
+	for _, entry := range f.Sections {
+		var offset uint32
+		if entry.HasFileData() {
+			offset = entry.RawOffset
+		}
+
+		size := entry.MappedSize()
+
+		data := entry.Raw
+		if uint32(len(data)) > size {
+			data = data[:size]
+		}
+
+		img.Sections = append(img.Sections, ImageSection{
+			Name:            entry.Name,
+			Address:         f.Windows.ImageBase + entry.VirtualAddress,
+			Size:            size,
+			Characteristics: entry.Characteristics,
+			Offset:          offset,
+			Data:            data,
+		})
+	}
+
+	return img
+}
+
+func (s *ImageSection) End() uint32 {
+	return s.Address + s.Size
+}
+
+func (s *ImageSection) Contains(va uint32) bool {
+	return va >= s.Address && va - s.Address < s.Size
+}
+
+// Returns the full virtual contents of the section, including the zero-filled tail
+func (s *ImageSection) Bytes() []byte {
+	if uint32(len(s.Data)) == s.Size {
+		return s.Data
+	}
+
+	data := make([]byte, s.Size)
+	copy(data, s.Data)
+	return data
+}
+
+func (img *Image) VAToRVA(va uint32) uint32 {
+	return va - img.Base
+}
+
+func (img *Image) RVAToVA(rva uint32) uint32 {
+	return rva + img.Base
+}
+
+func (img *Image) SectionAt(va uint32) *ImageSection {
+	for i, section := range img.Sections {
+		if section.Contains(va) {
+			return &img.Sections[i]
+		}
+	}
+
+	return nil
+}
+
+func (img *Image) GetSection(name string) *ImageSection {
+	for i, section := range img.Sections {
+		if section.Name == name {
+			return &img.Sections[i]
+		}
+	}
+
+	return nil
+}
+
+// Returns false if the address is not mapped or belongs to the zero-filled tail of a section
+func (img *Image) VAToOffset(va uint32) (uint32, bool) {
+	section := img.SectionAt(va)
+	if section == nil {
+		return 0, false
+	}
+
+	delta := va - section.Address
+	if delta >= uint32(len(section.Data)) {
+		return 0, false
+	}
+
+	return section.Offset + delta, true
+}
+
+func (img *Image) OffsetToVA(offset uint32) (uint32, bool) {
+	for _, section := range img.Sections {
+		if offset >= section.Offset && offset - section.Offset < uint32(len(section.Data)) {
+			return section.Address + offset - section.Offset, true
+		}
+	}
+
+	return 0, false
+}
+
+func (img *Image) RVAToOffset(rva uint32) (uint32, bool) {
+	return img.VAToOffset(img.RVAToVA(rva))
+}
+
+func (img *Image) OffsetToRVA(offset uint32) (uint32, bool) {
+	va, ok := img.OffsetToVA(offset)
+	return img.VAToRVA(va), ok
+}
+
+// ReadAt implements io.ReaderAt over the virtual address space, off being a VA.
+// Reads may span several adjacent sections, but not unmapped gaps.
+func (img *Image) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 || off > 0xffffffff {
+		return 0, fmt.Errorf("Address out of range: %x", off)
+	}
+
+	n := 0
+	va := uint32(off)
+	for n < len(p) {
+		section := img.SectionAt(va)
+		if section == nil {
+			if n == 0 && va >= img.end() {
+				return 0, io.EOF
+			}
+			return n, fmt.Errorf("Address is not mapped: %08x", va)
+		}
+
+		delta := va - section.Address
+		chunk := min(uint32(len(p) - n), section.Size - delta)
+
+		copied := 0
+		if delta < uint32(len(section.Data)) {
+			copied = copy(p[n:][:chunk], section.Data[delta:])
+		}
+		clear(p[n + copied:][:int(chunk) - copied])
+
+		n += int(chunk)
+		va += chunk
+	}
+
+	return n, nil
+}
+
+// Returns a copy of size bytes at the given VA
+func (img *Image) Bytes(va, size uint32) ([]byte, error) {
+	data := make([]byte, size)
+	if _, err := img.ReadAt(data, int64(va)); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (img *Image) end() uint32 {
+	var end uint32
+	for _, section := range img.Sections {
+		end = max(end, section.End())
+	}
+
+	return end
+}
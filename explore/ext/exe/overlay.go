@@ -0,0 +1,149 @@
+package exe
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/dexter3k/watre/explore/ext/codeview"
+	"github.com/dexter3k/watre/explore/ext/watcomdbg"
+)
+
+type OverlayKind int
+const (
+	OverlayNone OverlayKind = iota
+	OverlayUnknown
+	OverlayElf
+	OverlayWatcomDebug
+	OverlayCodeView
+)
+
+func (k OverlayKind) String() string {
+	switch k {
+	case OverlayNone:
+		return "none"
+	case OverlayUnknown:
+		return "unknown"
+	case OverlayElf:
+		return "ELF"
+	case OverlayWatcomDebug:
+		return "Watcom debug info"
+	case OverlayCodeView:
+		return "CodeView"
+	default:
+		return fmt.Sprintf("OverlayKind(%d)", int(k))
+	}
+}
+
+func DetectOverlayKind(data []byte) OverlayKind {
+	if len(data) == 0 {
+		return OverlayNone
+	}
+
+	if bytes.HasPrefix(data, []byte(elf.ELFMAG)) {
+		return OverlayElf
+	}
+
+	// Both Watcom and CodeView debug info are located by a trailer at the very end
+	if len(data) >= 14 && binary.LittleEndian.Uint16(data[len(data) - 14:]) == 0x8386 {
+		return OverlayWatcomDebug
+	}
+	if len(data) >= 8 {
+		signature := string(data[len(data) - 8:][:4])
+		if signature == "NB09" || signature == "NB11" {
+			return OverlayCodeView
+		}
+	}
+
+	return OverlayUnknown
+}
+
+// Each parser looks for its own debug format and leaves the file untouched if there is none
+var debugParsers = []func(f *File) error{
+	parseDwarfOverlay,
+	parseWatcomOverlay,
+	parseCodeView,
+}
+
+func parseDwarfOverlay(f *File) error {
+	if f.OverlayKind != OverlayElf {
+		return nil
+	}
+
+	elfFile, err := elf.NewFile(bytes.NewReader(f.Overlay))
+	if err != nil {
+		return fmt.Errorf("ELF overlay: %w", err)
+	}
+
+	dbg, err := elfFile.DWARF()
+	if err != nil {
+		return fmt.Errorf("ELF overlay: %w", err)
+	}
+
+	f.Dwarf = dbg
+	return nil
+}
+
+func parseWatcomOverlay(f *File) error {
+	if f.OverlayKind != OverlayWatcomDebug {
+		return nil
+	}
+
+	info, err := watcomdbg.Parse(f.Overlay)
+	if err != nil {
+		return fmt.Errorf("Watcom debug info: %w", err)
+	}
+
+	f.Watcom = info
+	return nil
+}
+
+// Translates a segmented Watcom debug address into a VA
+func (f *File) WatcomAddress(addr watcomdbg.Address) (uint32, bool) {
+	return f.SegmentAddress(addr.Segment, addr.Offset)
+}
+
+// CodeView info is either appended to the file or referenced from the debug directory
+func parseCodeView(f *File) error {
+	var data []byte
+	if f.OverlayKind == OverlayCodeView {
+		data, _ = codeview.FindAppended(f.Overlay)
+	}
+
+	if data == nil {
+		entries, err := f.DebugDirectory()
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			if entry.Type != DebugCodeView || entry.SizeOfData < 8 {
+				continue
+			}
+
+			blob, err := f.DebugData(entry)
+			if err != nil {
+				return fmt.Errorf("CodeView debug entry: %w", err)
+			}
+
+			// PDB references (NB10, RSDS) are not embedded debug info
+			if string(blob[:4]) == "NB09" || string(blob[:4]) == "NB11" {
+				data = blob
+				break
+			}
+		}
+	}
+
+	if data == nil {
+		return nil
+	}
+
+	info, err := codeview.Parse(data)
+	if err != nil {
+		return fmt.Errorf("CodeView: %w", err)
+	}
+
+	f.CodeView = info
+	return nil
+}
@@ -5,10 +5,155 @@ import (
 	"encoding/binary"
 	"fmt"
 	"bytes"
-	"debug/elf"
+	"cmp"
+	"slices"
+
+	"github.com/dexter3k/watre/explore/ext/mmap"
 )
 
+// Reads the whole file into memory, debug info and overlay included
 func Read(f io.ReadSeeker) (*File, error) {
+	if _, err := f.Seek(0, 0); err != nil {
+		return nil, err
+	}
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	file.LoadDebugInfo()
+	return file, nil
+}
+
+// Parses the file in place: section data and the overlay are slices of data,
+// nothing is copied. Debug info is only parsed once LoadDebugInfo is called,
+// so the overlay is not touched until then.
+func Parse(data []byte) (*File, error) {
+	file, err := readHeaders(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	for i, entry := range file.Sections {
+		if !entry.HasFileData() {
+			continue
+		}
+
+		end := uint64(entry.RawOffset) + uint64(entry.RawSize)
+		if end > uint64(len(data)) {
+			return nil, fmt.Errorf("%s: Raw data is out of bounds: %08x+%x", entry.Name, entry.RawOffset, entry.RawSize)
+		}
+
+		file.Sections[i].Raw = data[entry.RawOffset:end:end]
+	}
+
+	for _, gap := range file.gapRanges() {
+		file.Gaps = append(file.Gaps, Gap{gap[0], data[gap[0]:gap[1]:gap[1]]})
+	}
+
+	offsetAfterSections := min(file.dataEnd(), int64(len(data)))
+	file.setOverlay(data[offsetAfterSections:], offsetAfterSections)
+
+	file.source = data
+	file.parsedSizes = file.sectionSizes()
+	return file, nil
+}
+
+// Maps the file into memory and parses it in place, see Parse.
+// Section data stays valid until Close. Writes to it are private to the process.
+func Open(path string) (*File, error) {
+	m, err := mmap.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := Parse(m.Data)
+	if err != nil {
+		m.Close()
+		return nil, err
+	}
+
+	file.closer = m.Close
+	return file, nil
+}
+
+func (f *File) Close() error {
+	if f.closer == nil {
+		return nil
+	}
+
+	err := f.closer()
+	f.closer = nil
+	return err
+}
+
+// Offset of the end of headers and section data, the overlay starts here
+func (f *File) dataEnd() int64 {
+	end := int64(f.Windows.SizeOfHeaders)
+	for _, entry := range f.Sections {
+		if entry.HasFileData() {
+			end = max(end, int64(entry.RawOffset) + int64(entry.RawSize))
+		}
+	}
+
+	return end
+}
+
+// File ranges between the headers and the end of section data no section covers
+func (f *File) gapRanges() [][2]uint32 {
+	var covered [][2]uint32
+	for _, entry := range f.Sections {
+		if entry.HasFileData() {
+			covered = append(covered, [2]uint32{entry.RawOffset, entry.RawOffset + entry.RawSize})
+		}
+	}
+	slices.SortFunc(covered, func(a, b [2]uint32) int {
+		return cmp.Compare(a[0], b[0])
+	})
+
+	var gaps [][2]uint32
+	cursor := f.Windows.SizeOfHeaders
+	for _, r := range covered {
+		if r[0] > cursor {
+			gaps = append(gaps, [2]uint32{cursor, r[0]})
+		}
+		cursor = max(cursor, r[1])
+	}
+
+	return gaps
+}
+
+func (f *File) setOverlay(data []byte, offset int64) {
+	if len(data) == 0 {
+		return
+	}
+
+	f.Overlay = data
+	f.OverlayOffset = offset
+	f.OverlayKind = DetectOverlayKind(data)
+}
+
+// Parses whatever debug info the file carries. Debug info is optional,
+// so failures are collected in DebugErrors instead of being returned.
+func (f *File) LoadDebugInfo() {
+	f.Dwarf, f.Watcom, f.CodeView = nil, nil, nil
+	f.DebugErrors = nil
+	for _, parser := range debugParsers {
+		if err := parser(f); err != nil {
+			f.DebugErrors = append(f.DebugErrors, err)
+		}
+	}
+}
+
+// Reads everything up to SizeOfHeaders. Offsets are checked against the
+// size of the input before anything is allocated from them.
+func readHeaders(f io.ReadSeeker, size int64) (*File, error) {
 	var dosHeader DosHeader
 	if err := binary.Read(f, binary.LittleEndian, &dosHeader); err != nil {
 		return nil, err
@@ -16,7 +161,11 @@ func Read(f io.ReadSeeker) (*File, error) {
 	if dosHeader.Magic != 0x5a4d {
 		return nil, fmt.Errorf("Invalid DOS Header Magic: %04x", dosHeader.Magic)
 	}
-	if _, err := f.Seek(int64(dosHeader.PeHeaderOffset) - 64, 1); err != nil {
+	if dosHeader.PeHeaderOffset < 64 || int64(dosHeader.PeHeaderOffset) > size {
+		return nil, fmt.Errorf("Invalid PE Header offset: %08x", dosHeader.PeHeaderOffset)
+	}
+	dosStub := make([]byte, dosHeader.PeHeaderOffset - 64)
+	if _, err := io.ReadFull(f, dosStub); err != nil {
 		return nil, err
 	}
 
@@ -54,7 +203,8 @@ func Read(f io.ReadSeeker) (*File, error) {
 		return nil, fmt.Errorf("Provided data dir count is not equal to calculated: %d vs %d", windowsFields.DataDirEntries, dataDirCountFromSize)
 	}
 
-	if _, err := f.Seek(int64(windowsFields.DataDirEntries) * 8, 1); err != nil {
+	dataDirectories := make([]DataDirectory, windowsFields.DataDirEntries)
+	if err := binary.Read(f, binary.LittleEndian, dataDirectories); err != nil {
 		return nil, err
 	}
 
@@ -86,68 +236,51 @@ func Read(f io.ReadSeeker) (*File, error) {
 		if err := binary.Read(f, binary.LittleEndian, &entry.RawOffset); err != nil {
 			return nil, err
 		}
-		if err := binary.Read(f, binary.LittleEndian, entry.Unparsed[:]); err != nil {
+		if err := binary.Read(f, binary.LittleEndian, &entry.RelocationsPointer); err != nil {
 			return nil, err
 		}
-
-		entry.VirtualSize = max(entry.VirtualSize, entry.RawSize)
-		if entry.RawOffset == 0 || entry.RawSize == 0 {
-			entry.RawOffset = 0
-			entry.RawSize = 0
+		if err := binary.Read(f, binary.LittleEndian, &entry.LineNumbersPointer); err != nil {
+			return nil, err
 		}
-
-		sections = append(sections, entry)
-	}
-
-	var offsetAfterSections int64
-
-	for i, entry := range sections {
-		if entry.RawSize > 0 {
-			offsetAfterSections = max(offsetAfterSections, int64(entry.RawOffset) + int64(entry.RawSize))
-
-			if _, err := f.Seek(int64(entry.RawOffset), 0); err != nil {
-				return nil, err
-			}
-
-			data := make([]byte, entry.RawSize)
-			if err := binary.Read(f, binary.LittleEndian, data); err != nil {
-				return nil, err
-			}
-
-			sections[i].Raw = data
+		if err := binary.Read(f, binary.LittleEndian, &entry.RelocationCount); err != nil {
+			return nil, err
+		}
+		if err := binary.Read(f, binary.LittleEndian, &entry.LineNumberCount); err != nil {
+			return nil, err
+		}
+		if err := binary.Read(f, binary.LittleEndian, &entry.Characteristics); err != nil {
+			return nil, err
 		}
-	}
 
-	// Find out if there is anything past the section data
-	if _, err := f.Seek(offsetAfterSections, 0); err != nil {
-		return nil, err
+		sections = append(sections, entry)
 	}
 
-	data, err := io.ReadAll(f)
+	// Whatever follows the section table within the headers is kept for writing
+	headersEnd, err := f.Seek(0, 1)
 	if err != nil {
 		return nil, err
 	}
+	if int64(windowsFields.SizeOfHeaders) > size {
+		return nil, fmt.Errorf("SizeOfHeaders is past the end of the file: %08x", windowsFields.SizeOfHeaders)
+	}
+	var headerPadding []byte
+	if int64(windowsFields.SizeOfHeaders) > headersEnd {
+		headerPadding = make([]byte, int64(windowsFields.SizeOfHeaders) - headersEnd)
+		if _, err := io.ReadFull(f, headerPadding); err != nil {
+			return nil, err
+		}
+	}
 
 	file := File{
 		Dos:      dosHeader,
+		DosStub:  dosStub,
 		Pe:       peHeader,
 		Standard: standardFields,
 		Windows:  windowsFields,
 		Sections: sections,
-	}
-
-	if len(data) > 0 {
-		elfFile, err := elf.NewFile(bytes.NewReader(data))
-		if err != nil {
-			return nil, err
-		}
-
-		dbg, err := elfFile.DWARF()
-		if err != nil {
-			return nil, err
-		}
 
-		file.Dwarf = dbg
+		DataDirectories: dataDirectories,
+		HeaderPadding:   headerPadding,
 	}
 
 	return &file, nil
@@ -0,0 +1,61 @@
+package exe
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+func TestParseMalformed(t *testing.T) {
+	le := binary.LittleEndian
+	// Offsets within the fixture
+	const (
+		peHeaderAt      = 0x80
+		sizeOfHeadersAt = peHeaderAt + peHeaderSize + 60
+		sectionTableAt  = 0x178
+	)
+
+	tests := []struct {
+		name   string
+		mutate func(data []byte) []byte
+		errors string
+	}{
+		{"PE header offset past the end", func(data []byte) []byte {
+			data = data[:256]
+			le.PutUint32(data[0x3c:], 0xff000000)
+			return data
+		}, "Invalid PE Header offset"},
+		{"PE header offset within the DOS header", func(data []byte) []byte {
+			le.PutUint32(data[0x3c:], 0x20)
+			return data
+		}, "Invalid PE Header offset"},
+		{"bad DOS magic", func(data []byte) []byte {
+			data[0] = 'Z'
+			return data
+		}, "DOS Header Magic"},
+		{"bad PE magic", func(data []byte) []byte {
+			data[peHeaderAt] = 'X'
+			return data
+		}, "PE Header Magic"},
+		{"SizeOfHeaders past the end", func(data []byte) []byte {
+			le.PutUint32(data[sizeOfHeadersAt:], 0xfff00000)
+			return data
+		}, "SizeOfHeaders"},
+		{"raw data past the end", func(data []byte) []byte {
+			le.PutUint32(data[sectionTableAt + 16:], 0x10000000)
+			return data
+		}, "out of bounds"},
+		{"truncated section table", func(data []byte) []byte {
+			return data[:sectionTableAt + 20]
+		}, "EOF"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse(test.mutate(mustRead(t, fixture)))
+			if err == nil || !strings.Contains(err.Error(), test.errors) {
+				t.Fatalf("Expected an error containing %q, got %v", test.errors, err)
+			}
+		})
+	}
+}
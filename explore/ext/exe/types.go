@@ -2,23 +2,64 @@ package exe
 
 import (
 	"debug/dwarf"
+
+	"github.com/dexter3k/watre/explore/ext/codeview"
+	"github.com/dexter3k/watre/explore/ext/watcomdbg"
 )
 
 type DosHeader struct {
-	Magic          uint16
-	Unparsed       [64 - 2 - 4]byte
+	Magic            uint16
+	LastPageBytes    uint16
+	PageCount        uint16
+	RelocationCount  uint16
+	HeaderParagraphs uint16
+	MinAlloc         uint16
+	MaxAlloc         uint16
+	InitialSS        uint16
+	InitialSP        uint16
+	Checksum         uint16
+	InitialIP        uint16
+	InitialCS        uint16
+	RelocationOffset uint16
+	OverlayNumber    uint16
+	Reserved         [4]uint16
+	OemId            uint16
+	OemInfo          uint16
+	Reserved2        [10]uint16
+
+	// Also used for NE and LE/LX headers
 	PeHeaderOffset uint32
 }
 
+func (h *DosHeader) HeaderSize() uint32 {
+	return uint32(h.HeaderParagraphs) * 16
+}
+
+// Size of the file as loaded by DOS, headers included.
+// Anything past this size is an overlay.
+func (h *DosHeader) FileSize() uint32 {
+	if h.PageCount == 0 {
+		return 0
+	}
+
+	size := uint32(h.PageCount) * 512
+	if h.LastPageBytes != 0 {
+		size -= 512 - uint32(h.LastPageBytes)
+	}
+	return size
+}
+
 type PeHeader struct {
 	Magic    uint32
 	Machine  uint16
 	Sections uint16
 
-	Unparsed [4 + 4 + 4]byte
+	TimeDateStamp      uint32
+	SymbolTablePointer uint32
+	SymbolCount        uint32
 
 	OptionalHeaderSize uint16
-	Characteristics    uint16
+	Characteristics    FileCharacteristics
 }
 
 type PeStandardFields struct {
@@ -66,6 +107,29 @@ type PeWindowsFields struct {
 	DataDirEntries uint32
 }
 
+type DataDirectory struct {
+	VirtualAddress uint32
+	Size           uint32
+}
+
+const (
+	DirectoryExport        = 0
+	DirectoryImport        = 1
+	DirectoryResource      = 2
+	DirectoryException     = 3
+	DirectorySecurity      = 4
+	DirectoryBaseReloc     = 5
+	DirectoryDebug         = 6
+	DirectoryCopyright     = 7
+	DirectoryGlobalPtr     = 8
+	DirectoryTls           = 9
+	DirectoryLoadConfig    = 10
+	DirectoryBoundImport   = 11
+	DirectoryIat           = 12
+	DirectoryDelayImport   = 13
+	DirectoryComDescriptor = 14
+)
+
 type SectionEntry struct {
 	Name string
 
@@ -75,16 +139,85 @@ type SectionEntry struct {
 	RawOffset      uint32
 	Raw            []byte
 
-	Unparsed [4 + 4 + 2 + 2 + 4]byte
+	RelocationsPointer uint32
+	LineNumbersPointer uint32
+	RelocationCount    uint16
+	LineNumberCount    uint16
+	Characteristics    SectionCharacteristics
 }
 
 type File struct {
 	Dos      DosHeader
+	// Everything between the DOS header and the PE header
+	DosStub  []byte
 	Pe       PeHeader
 	Standard PeStandardFields
 	Windows  PeWindowsFields
 	Sections []SectionEntry
+
+	DataDirectories []DataDirectory
+	// Bytes between the section table and SizeOfHeaders
+	HeaderPadding []byte
+	// Bytes between SizeOfHeaders and the overlay that no section covers
+	Gaps []Gap
+
+	// Anything found past the last section
+	Overlay       []byte
+	OverlayOffset int64
+	OverlayKind   OverlayKind
+
 	Dwarf    *dwarf.Data
+	Watcom   *watcomdbg.Info
+	CodeView *codeview.Info
+
+	// Errors from debug info parsers, these never fail the whole read
+	DebugErrors []error
+
+	// The bytes the file was parsed from, dropped once the headers or layout change
+	source []byte
+	// Sizes implied by the sections when the header size fields were last set
+	parsedSizes [3]uint32
+
+	// Releases the mapping of files opened with Open
+	closer func() error
+}
+
+// Part of the file outside of the headers and all sections
+type Gap struct {
+	Offset uint32
+	Data   []byte
+}
+
+func (f *File) Image() (*Image, error) {
+	return NewImage(f), nil
+}
+
+func (f *File) GetDataDirectory(index int) (DataDirectory, bool) {
+	if index >= len(f.DataDirectories) || f.DataDirectories[index].VirtualAddress == 0 {
+		return DataDirectory{}, false
+	}
+
+	return f.DataDirectories[index], true
+}
+
+// Returns the section containing the given RVA and the offset within it
+func (f *File) SectionByRVA(rva uint32) (*SectionEntry, uint32) {
+	for i, entry := range f.Sections {
+		if rva >= entry.VirtualAddress && rva - entry.VirtualAddress < entry.MappedSize() {
+			return &f.Sections[i], rva - entry.VirtualAddress
+		}
+	}
+
+	return nil, 0
+}
+
+// Translates segment:offset as used by debug formats into a VA. Segments are 1-based section numbers.
+func (f *File) SegmentAddress(segment uint16, offset uint32) (uint32, bool) {
+	if segment == 0 || int(segment) > len(f.Sections) {
+		return 0, false
+	}
+
+	return f.Windows.ImageBase + f.Sections[segment - 1].VirtualAddress + offset, true
 }
 
 func (f *File) GetSection(name string) *SectionEntry {
@@ -98,3 +231,39 @@ func (f *File) GetSection(name string) *SectionEntry {
 
 	return nil
 }
+
+func (f *File) SectionsWith(flags SectionCharacteristics) []*SectionEntry {
+	var result []*SectionEntry
+	for i, entry := range f.Sections {
+		if !entry.Characteristics.Has(flags) {
+			continue
+		}
+
+		result = append(result, &f.Sections[i])
+	}
+
+	return result
+}
+
+// Watcom linkers write the size of BSS into the raw size field of the section
+// instead of the virtual size field. Such sections have no data in the file.
+func (s *SectionEntry) HasWatcomBssQuirk() bool {
+	if !s.Characteristics.IsUninitializedData() && s.Name != "BSS" && s.Name != ".bss" {
+		return false
+	}
+
+	return s.RawSize > 0 && s.RawSize > s.VirtualSize
+}
+
+func (s *SectionEntry) HasFileData() bool {
+	return s.RawOffset != 0 && s.RawSize != 0 && !s.HasWatcomBssQuirk()
+}
+
+// Size of the section once mapped into memory
+func (s *SectionEntry) MappedSize() uint32 {
+	if s.HasWatcomBssQuirk() || s.VirtualSize == 0 {
+		return s.RawSize
+	}
+
+	return s.VirtualSize
+}
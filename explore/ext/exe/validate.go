@@ -0,0 +1,122 @@
+package exe
+
+import (
+	"fmt"
+	"sort"
+)
+
+type Severity int
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	default:
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+}
+
+type Finding struct {
+	Severity Severity
+	// Empty for findings about the file as a whole
+	Section string
+	Message string
+}
+
+func (f Finding) String() string {
+	if f.Section == "" {
+		return fmt.Sprintf("%s: %s", f.Severity, f.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", f.Severity, f.Section, f.Message)
+}
+
+// Checks the headers for inconsistencies that loaders tolerate, but
+// which hint at packed, tampered or unusually linked executables
+func (f *File) Validate() []Finding {
+	var findings []Finding
+	report := func(severity Severity, section string, format string, args ...any) {
+		findings = append(findings, Finding{
+			Severity: severity,
+			Section:  section,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	if f.Windows.CheckSum == 0 {
+		report(SeverityInfo, "", "Checksum is not set")
+	} else if checksum, err := f.ComputeChecksum(); err != nil {
+		report(SeverityWarning, "", "Unable to compute checksum: %v", err)
+	} else if checksum != f.Windows.CheckSum {
+		report(SeverityWarning, "", "Checksum mismatch: %08x in header, %08x computed", f.Windows.CheckSum, checksum)
+	}
+
+	if size := f.imageSize(); size != f.Windows.SizeOfImage {
+		report(SeverityWarning, "", "SizeOfImage mismatch: %08x in header, %08x from sections", f.Windows.SizeOfImage, size)
+	}
+
+	for _, entry := range f.Sections {
+		if entry.HasWatcomBssQuirk() {
+			report(SeverityInfo, entry.Name, "Uninitialized size %x is stored as raw size (Watcom)", entry.RawSize)
+		}
+		if entry.VirtualAddress % max(f.Windows.SectionAlign, 1) != 0 {
+			report(SeverityWarning, entry.Name, "Address %08x is not aligned to %x", entry.VirtualAddress, f.Windows.SectionAlign)
+		}
+		if entry.HasFileData() && entry.RawOffset % max(f.Windows.FileAlign, 1) != 0 {
+			report(SeverityWarning, entry.Name, "Raw data at %08x is not aligned to %x", entry.RawOffset, f.Windows.FileAlign)
+		}
+		if entry.Characteristics.IsExecutable() && entry.Characteristics.IsWritable() {
+			report(SeverityWarning, entry.Name, "Section is both writable and executable")
+		}
+	}
+
+	// Sort copies by address and by file offset, neighbours must not overlap
+	sections := append([]SectionEntry(nil), f.Sections...)
+	sort.SliceStable(sections, func(i, j int) bool {
+		return sections[i].VirtualAddress < sections[j].VirtualAddress
+	})
+	for i := 1; i < len(sections); i++ {
+		prev, next := sections[i - 1], sections[i]
+		if prev.VirtualAddress + prev.MappedSize() > next.VirtualAddress {
+			report(SeverityError, next.Name, "Overlaps %s in memory at %08x", prev.Name, next.VirtualAddress)
+		}
+	}
+
+	var withData []SectionEntry
+	for _, entry := range sections {
+		if entry.HasFileData() {
+			withData = append(withData, entry)
+		}
+	}
+	sort.SliceStable(withData, func(i, j int) bool {
+		return withData[i].RawOffset < withData[j].RawOffset
+	})
+	for i, entry := range withData {
+		if entry.RawOffset < f.Windows.SizeOfHeaders {
+			report(SeverityError, entry.Name, "Raw data at %08x overlaps the headers", entry.RawOffset)
+		}
+		if i > 0 && withData[i - 1].RawOffset + withData[i - 1].RawSize > entry.RawOffset {
+			report(SeverityError, entry.Name, "Overlaps %s in the file at %08x", withData[i - 1].Name, entry.RawOffset)
+		}
+	}
+
+	if entry := f.Standard.EntryPoints; entry != 0 {
+		section, _ := f.SectionByRVA(entry)
+		switch {
+		case section == nil:
+			report(SeverityError, "", "Entry point %08x is outside of all sections", entry)
+		case !section.Characteristics.IsCode() && !section.Characteristics.IsExecutable():
+			report(SeverityWarning, section.Name, "Entry point %08x is outside of code", entry)
+		}
+	}
+
+	return findings
+}
@@ -0,0 +1,148 @@
+package exe
+
+import (
+	"encoding/binary"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		mutate   func(f *File)
+		severity Severity
+		section  string
+		finding  string
+	}{
+		{"overlapping sections in memory", func(f *File) {
+			f.Sections[1].VirtualAddress = 0x1000
+		}, SeverityError, ".data", "Overlaps .text in memory"},
+		{"overlapping sections in the file", func(f *File) {
+			f.Sections[2].RawOffset = 0x1200
+		}, SeverityError, ".rdata", "Overlaps .data in the file"},
+		{"raw data within the headers", func(f *File) {
+			f.Sections[0].RawOffset = 0x200
+		}, SeverityError, ".text", "overlaps the headers"},
+		{"SizeOfImage mismatch", func(f *File) {
+			f.Windows.SizeOfImage = 0x20000
+		}, SeverityWarning, "", "SizeOfImage mismatch: 00020000 in header, 00010000 from sections"},
+		{"entry point outside of code", func(f *File) {
+			f.Standard.EntryPoints = 0x2004
+		}, SeverityWarning, ".data", "outside of code"},
+		{"entry point outside of all sections", func(f *File) {
+			f.Standard.EntryPoints = 0x100000
+		}, SeverityError, "", "outside of all sections"},
+		{"misaligned raw data", func(f *File) {
+			f.Sections[1].RawOffset = 0x1210
+		}, SeverityWarning, ".data", "Raw data at 00001210 is not aligned to 200"},
+		{"misaligned address", func(f *File) {
+			f.Sections[1].VirtualAddress = 0x2100
+		}, SeverityWarning, ".data", "Address 00002100 is not aligned to 1000"},
+		{"writable code", func(f *File) {
+			f.Sections[0].Characteristics |= SectionWrite
+		}, SeverityWarning, ".text", "both writable and executable"},
+		{"Watcom BSS quirk", func(f *File) {
+			f.Sections[3].RawSize = 0x1000
+		}, SeverityInfo, ".bss", "Uninitialized size 1000 is stored as raw size"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			file, err := Parse(mustRead(t, fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if findings := file.Validate(); len(findings) != 0 {
+				t.Fatalf("Unmodified fixture has findings: %v", findings)
+			}
+
+			test.mutate(file)
+			findings := file.Validate()
+			for _, finding := range findings {
+				if finding.Severity == test.severity && finding.Section == test.section && strings.Contains(finding.Message, test.finding) {
+					return
+				}
+			}
+			t.Errorf("Findings are %v, expected %s: %s: %s", findings, test.severity, test.section, test.finding)
+		})
+	}
+}
+
+func TestValidateChecksum(t *testing.T) {
+	original, err := os.ReadFile(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checksumAt := int(binary.LittleEndian.Uint32(original[0x3c:])) + peHeaderSize + checksumFieldOffset
+
+	tests := []struct {
+		name     string
+		mutate   func(data []byte)
+		checksum func(data []byte) uint32
+		finding  string
+	}{
+		{"not set", nil, func([]byte) uint32 {
+			return 0
+		}, "not set"},
+		{"correct", nil, func(data []byte) uint32 {
+			return Checksum(data, checksumAt)
+		}, ""},
+		{"wrong", nil, func(data []byte) uint32 {
+			return Checksum(data, checksumAt) + 1
+		}, "mismatch"},
+		{"junk after the section name", func(data []byte) {
+			// Dropped on read, so only the original bytes checksum correctly
+			copy(data[0x178:], ".text\x00\x00X")
+		}, func(data []byte) uint32 {
+			return Checksum(data, checksumAt)
+		}, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := append([]byte(nil), original...)
+			if test.mutate != nil {
+				test.mutate(data)
+			}
+			binary.LittleEndian.PutUint32(data[checksumAt:], test.checksum(data))
+
+			file, err := Parse(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var found string
+			for _, finding := range file.Validate() {
+				if strings.Contains(finding.Message, "Checksum") {
+					found = finding.Message
+				}
+			}
+			if test.finding == "" && found != "" || !strings.Contains(found, test.finding) {
+				t.Errorf("Checksum finding is %q, expected %q", found, test.finding)
+			}
+		})
+	}
+}
+
+func TestChecksumAfterResize(t *testing.T) {
+	file, err := Parse(mustRead(t, fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := file.ResizeSection(0, 0x1000); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := file.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checksum, err := file.ComputeChecksum()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := Checksum(data, file.checksumOffset()); checksum != expected {
+		t.Errorf("Checksum of the resized file is %08x, expected %08x", checksum, expected)
+	}
+}
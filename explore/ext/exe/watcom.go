@@ -0,0 +1,136 @@
+package exe
+
+import (
+	"fmt"
+	"os"
+)
+
+// Layout of a Watcom-linked executable as seen by the matchers
+type WatcomExe struct {
+	Image *Image
+
+	CodeBase uint32
+	Code     []byte
+
+	DataBase uint32
+	Data     []byte
+
+	BssBase   uint32
+	BssLength uint32
+}
+
+type watcomSectionKind int
+const (
+	watcomSectionUnknown watcomSectionKind = iota
+	watcomSectionCode
+	watcomSectionData
+	watcomSectionBss
+	watcomSectionSystem
+)
+
+var watcomSectionNames = map[string]watcomSectionKind{
+	"AUTO":   watcomSectionCode,
+	"CODE":   watcomSectionCode,
+	".text":  watcomSectionCode,
+	"DGROUP": watcomSectionData,
+	"DATA":   watcomSectionData,
+	".data":  watcomSectionData,
+	"BSS":    watcomSectionBss,
+	".bss":   watcomSectionBss,
+
+	// Sections produced by the linker itself, never matched against
+	".idata": watcomSectionSystem,
+	".edata": watcomSectionSystem,
+	".reloc": watcomSectionSystem,
+	".rsrc":  watcomSectionSystem,
+	".tls":   watcomSectionSystem,
+}
+
+func classifyWatcomSection(section *ImageSection) watcomSectionKind {
+	flags := section.Characteristics
+	switch {
+	case flags.IsUninitializedData():
+		return watcomSectionBss
+	case flags.IsCode():
+		return watcomSectionCode
+	case flags.IsInitializedData() && flags.IsWritable():
+		return watcomSectionData
+	default:
+		return watcomSectionUnknown
+	}
+}
+
+// Reads the whole file with Read, overlay and debug info included
+func LoadWatcomExe(path string) (*WatcomExe, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	file, err := Read(f)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewWatcomExe(NewImage(file))
+}
+
+// Picks code, data and BSS sections out of the image. Known Watcom section names
+// take priority, the rest are classified by their characteristics.
+func NewWatcomExe(img *Image) (*WatcomExe, error) {
+	var picked [watcomSectionSystem]*ImageSection
+
+	for i, section := range img.Sections {
+		kind, found := watcomSectionNames[section.Name]
+		if !found || kind == watcomSectionSystem {
+			continue
+		}
+		if picked[kind] == nil {
+			picked[kind] = &img.Sections[i]
+		}
+	}
+
+	for i, section := range img.Sections {
+		if _, found := watcomSectionNames[section.Name]; found {
+			continue
+		}
+
+		kind := classifyWatcomSection(&section)
+		if kind == watcomSectionUnknown {
+			continue
+		}
+		if picked[kind] == nil {
+			picked[kind] = &img.Sections[i]
+		}
+	}
+
+	code := picked[watcomSectionCode]
+	data := picked[watcomSectionData]
+	bss := picked[watcomSectionBss]
+	if code == nil {
+		return nil, fmt.Errorf("No code section found")
+	}
+
+	watcom := &WatcomExe{
+		Image:    img,
+		CodeBase: code.Address,
+		Code:     code.Bytes(),
+	}
+
+	if data != nil {
+		watcom.DataBase = data.Address
+		watcom.Data = data.Bytes()
+	}
+
+	if bss != nil {
+		watcom.BssBase = bss.Address
+		watcom.BssLength = bss.Size
+	} else if data != nil && uint32(len(data.Data)) < data.Size {
+		// No dedicated BSS section, it is then placed in the tail of DGROUP
+		watcom.BssBase = data.Address + uint32(len(data.Data))
+		watcom.BssLength = data.Size - uint32(len(data.Data))
+	}
+
+	return watcom, nil
+}
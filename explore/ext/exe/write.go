@@ -0,0 +1,402 @@
+package exe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	dosHeaderSize      = 64
+	peHeaderSize       = 24
+	optionalHeaderSize = 96
+	sectionEntrySize   = 40
+	dataDirectorySize  = 8
+
+	// Offset of the checksum within the optional header
+	checksumFieldOffset = 64
+)
+
+// Rounds value up to a multiple of alignment, zero alignment leaves it as is
+func AlignUp(value, alignment uint32) uint32 {
+	if alignment == 0 {
+		return value
+	}
+
+	return (value + alignment - 1) / alignment * alignment
+}
+
+// Size of the headers up to and including the section table
+func (f *File) headersSize() uint32 {
+	return dosHeaderSize + uint32(len(f.DosStub)) + peHeaderSize + uint32(f.Pe.OptionalHeaderSize) + uint32(len(f.Sections)) * sectionEntrySize
+}
+
+// Serializes the file. Header fields are written as they are, so call
+// UpdateHeaders and UpdateChecksum after modifying the sections. Bytes
+// outside of the headers and sections are kept where they were read from,
+// unless the raw data around them moved.
+func (f *File) Bytes() ([]byte, error) {
+	le := binary.LittleEndian
+
+	if int(f.Pe.Sections) != len(f.Sections) {
+		return nil, fmt.Errorf("Section count mismatch: %d in header, %d sections", f.Pe.Sections, len(f.Sections))
+	}
+	if int(f.Windows.DataDirEntries) != len(f.DataDirectories) || int(f.Pe.OptionalHeaderSize) != optionalHeaderSize + len(f.DataDirectories) * dataDirectorySize {
+		return nil, fmt.Errorf("Data directory count mismatch: %d in header, %d directories", f.Windows.DataDirEntries, len(f.DataDirectories))
+	}
+	if uint32(len(f.DosStub)) + dosHeaderSize != f.Dos.PeHeaderOffset {
+		return nil, fmt.Errorf("DOS stub does not end at the PE header: %08x", f.Dos.PeHeaderOffset)
+	}
+
+	var buf bytes.Buffer
+	headers := []any{&f.Dos, f.DosStub, &f.Pe, &f.Standard, &f.Windows, f.DataDirectories}
+	for _, header := range headers {
+		if err := binary.Write(&buf, le, header); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, entry := range f.Sections {
+		if len(entry.Name) > 8 {
+			return nil, fmt.Errorf("Section name is too long: %q", entry.Name)
+		}
+
+		var name [8]byte
+		copy(name[:], entry.Name)
+		fields := []any{
+			name,
+			entry.VirtualSize,
+			entry.VirtualAddress,
+			entry.RawSize,
+			entry.RawOffset,
+			entry.RelocationsPointer,
+			entry.LineNumbersPointer,
+			entry.RelocationCount,
+			entry.LineNumberCount,
+			entry.Characteristics,
+		}
+		for _, field := range fields {
+			if err := binary.Write(&buf, le, field); err != nil {
+				return nil, err
+			}
+		}
+	}
+	buf.Write(f.HeaderPadding)
+
+	if buf.Len() > int(f.Windows.SizeOfHeaders) {
+		return nil, fmt.Errorf("Headers do not fit into SizeOfHeaders: %d > %d", buf.Len(), f.Windows.SizeOfHeaders)
+	}
+
+	out := buf.Bytes()
+	out = append(out, make([]byte, int(f.Windows.SizeOfHeaders) - len(out))...)
+
+	// Gaps go first, so that sections moved over them win
+	for _, gap := range f.Gaps {
+		end := int(gap.Offset) + len(gap.Data)
+		if end > len(out) {
+			out = append(out, make([]byte, end - len(out))...)
+		}
+		copy(out[gap.Offset:], gap.Data)
+	}
+
+	for _, entry := range f.Sections {
+		if !entry.HasFileData() {
+			continue
+		}
+		if entry.RawOffset < f.Windows.SizeOfHeaders {
+			return nil, fmt.Errorf("%s: Raw data overlaps the headers", entry.Name)
+		}
+		if uint32(len(entry.Raw)) > entry.RawSize {
+			return nil, fmt.Errorf("%s: Raw data is larger than the raw size: %d > %d", entry.Name, len(entry.Raw), entry.RawSize)
+		}
+
+		end := int(entry.RawOffset) + int(entry.RawSize)
+		if end > len(out) {
+			out = append(out, make([]byte, end - len(out))...)
+		}
+		copy(out[entry.RawOffset:end], entry.Raw)
+		clear(out[int(entry.RawOffset) + len(entry.Raw):end])
+	}
+
+	return append(out, f.Overlay...), nil
+}
+
+func (f *File) Write(w io.Writer) error {
+	data, err := f.Bytes()
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+	return err
+}
+
+func (f *File) WriteFile(path string) error {
+	data, err := f.Bytes()
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0666)
+}
+
+// SizeOfImage as implied by the sections
+func (f *File) imageSize() uint32 {
+	end := AlignUp(f.Windows.SizeOfHeaders, f.Windows.SectionAlign)
+	for _, entry := range f.Sections {
+		end = max(end, AlignUp(entry.VirtualAddress + entry.MappedSize(), f.Windows.SectionAlign))
+	}
+
+	return end
+}
+
+// SizeOfCode, SizeOfInitializedData and SizeOfUninitializedData as implied
+// by the sections
+func (f *File) sectionSizes() [3]uint32 {
+	var sizes [3]uint32
+	for _, entry := range f.Sections {
+		size := entry.MappedSize()
+		if entry.HasFileData() {
+			size = entry.RawSize
+		}
+		size = AlignUp(size, f.Windows.FileAlign)
+
+		switch {
+		case entry.Characteristics.IsCode():
+			sizes[0] += size
+		case entry.Characteristics.IsInitializedData():
+			sizes[1] += size
+		case entry.Characteristics.IsUninitializedData():
+			sizes[2] += size
+		}
+	}
+
+	return sizes
+}
+
+// Recomputes counts, sizes and the overlay offset from the sections.
+// Linkers disagree on what the size fields count, so they are only moved
+// by how much the sections changed since the file was parsed. The checksum
+// is left alone, see UpdateChecksum.
+func (f *File) UpdateHeaders() {
+	f.source = nil
+	f.Pe.Sections = uint16(len(f.Sections))
+	f.Pe.OptionalHeaderSize = uint16(optionalHeaderSize + len(f.DataDirectories) * dataDirectorySize)
+	f.Windows.DataDirEntries = uint32(len(f.DataDirectories))
+
+	sizes := f.sectionSizes()
+	f.Windows.SizeOfImage = f.imageSize()
+	f.Standard.SizeOfCode += sizes[0] - f.parsedSizes[0]
+	f.Standard.SizeOfInit += sizes[1] - f.parsedSizes[1]
+	f.Standard.SizeOfUninit += sizes[2] - f.parsedSizes[2]
+	f.parsedSizes = sizes
+
+	if end := f.dataEnd(); len(f.Overlay) > 0 && end != f.OverlayOffset {
+		f.moveFileOffsets(uint32(f.OverlayOffset), uint32(end - f.OverlayOffset))
+	}
+}
+
+// Standard PE checksum: a folded 16-bit sum of the file with the
+// checksum field skipped, plus the length of the file
+func Checksum(data []byte, checksumOffset int) uint32 {
+	var sum uint32
+	for i := 0; i < len(data); i += 2 {
+		if i >= checksumOffset && i < checksumOffset + 4 {
+			continue
+		}
+
+		word := uint32(data[i])
+		if i + 1 < len(data) {
+			word |= uint32(data[i + 1]) << 8
+		}
+		sum += word
+		sum = (sum & 0xffff) + (sum >> 16)
+	}
+
+	return sum + uint32(len(data))
+}
+
+func (f *File) checksumOffset() int {
+	return int(f.Dos.PeHeaderOffset) + peHeaderSize + checksumFieldOffset
+}
+
+// Checksums the bytes the file was read from, or what Bytes would write
+// once the file has been changed through UpdateHeaders or the layout methods
+func (f *File) ComputeChecksum() (uint32, error) {
+	data := f.source
+	if data == nil {
+		var err error
+		if data, err = f.Bytes(); err != nil {
+			return 0, err
+		}
+	}
+
+	return Checksum(data, f.checksumOffset()), nil
+}
+
+func (f *File) UpdateChecksum() error {
+	checksum, err := f.ComputeChecksum()
+	if err != nil {
+		return err
+	}
+
+	f.Windows.CheckSum = checksum
+	return nil
+}
+
+// Overwrites bytes at the given VA. The patch must lie within the raw data of one section.
+func (f *File) Patch(va uint32, data []byte) error {
+	entry, offset := f.SectionByRVA(va - f.Windows.ImageBase)
+	if entry == nil {
+		return fmt.Errorf("No section at %08x", va)
+	}
+	if uint64(offset) + uint64(len(data)) > uint64(len(entry.Raw)) {
+		return fmt.Errorf("Patch at %08x+%x is outside of the raw data of %s", va, len(data), entry.Name)
+	}
+
+	copy(entry.Raw[offset:], data)
+	return nil
+}
+
+// Moves gaps, the overlay and header fields pointing at or after offset
+// along with the raw data around them. Zero pointers mean there is nothing
+// to point at and stay. Negative deltas wrap, as they do for raw offsets.
+func (f *File) moveFileOffsets(offset, delta uint32) {
+	move := func(pointer *uint32) {
+		if *pointer != 0 && *pointer >= offset {
+			*pointer += delta
+		}
+	}
+
+	for i := range f.Gaps {
+		move(&f.Gaps[i].Offset)
+	}
+	for i := range f.Sections {
+		move(&f.Sections[i].RelocationsPointer)
+		move(&f.Sections[i].LineNumbersPointer)
+	}
+	move(&f.Pe.SymbolTablePointer)
+
+	if f.OverlayOffset >= int64(offset) {
+		f.OverlayOffset = int64(uint32(f.OverlayOffset) + delta)
+	}
+}
+
+// Makes room for one more entry in the section table. Zeroed header padding
+// is consumed first, otherwise the headers grow and all raw data moves.
+func (f *File) growSectionTable() error {
+	f.source = nil
+	needed := f.headersSize() + sectionEntrySize
+	padding := f.HeaderPadding[:min(sectionEntrySize, len(f.HeaderPadding))]
+	if needed + uint32(len(f.HeaderPadding)) - uint32(len(padding)) <= f.Windows.SizeOfHeaders && bytes.Equal(padding, make([]byte, len(padding))) {
+		f.HeaderPadding = f.HeaderPadding[len(padding):]
+		return nil
+	}
+
+	size := AlignUp(needed + uint32(len(f.HeaderPadding)), f.Windows.FileAlign)
+	for _, entry := range f.Sections {
+		if entry.VirtualAddress < size {
+			return fmt.Errorf("No room for another section header before %s", entry.Name)
+		}
+	}
+
+	delta := size - f.Windows.SizeOfHeaders
+	for i := range f.Sections {
+		if f.Sections[i].HasFileData() {
+			f.Sections[i].RawOffset += delta
+		}
+	}
+	f.moveFileOffsets(0, delta)
+	f.Windows.SizeOfHeaders = size
+	return nil
+}
+
+// Appends a section after the last one, both in memory and in the file.
+// The virtual size is raised to the size of data if needed. The overlay
+// and header fields pointing into it, like the COFF symbol table, move past
+// the new section. File offsets stored within the data (such as debug
+// directory entries) are not updated.
+func (f *File) AddSection(name string, data []byte, virtualSize uint32, characteristics SectionCharacteristics) (*SectionEntry, error) {
+	if len(name) > 8 {
+		return nil, fmt.Errorf("Section name is too long: %q", name)
+	}
+	if err := f.growSectionTable(); err != nil {
+		return nil, err
+	}
+
+	imageEnd := f.Windows.SizeOfHeaders
+	fileEnd := f.Windows.SizeOfHeaders
+	for _, entry := range f.Sections {
+		imageEnd = max(imageEnd, entry.VirtualAddress + entry.MappedSize())
+		if entry.HasFileData() {
+			fileEnd = max(fileEnd, entry.RawOffset + entry.RawSize)
+		}
+	}
+
+	entry := SectionEntry{
+		Name:            name,
+		VirtualSize:     max(virtualSize, uint32(len(data))),
+		VirtualAddress:  AlignUp(imageEnd, f.Windows.SectionAlign),
+		Characteristics: characteristics,
+	}
+	if len(data) > 0 {
+		entry.RawOffset = AlignUp(fileEnd, f.Windows.FileAlign)
+		entry.RawSize = AlignUp(uint32(len(data)), f.Windows.FileAlign)
+		entry.Raw = append([]byte(nil), data...)
+	}
+
+	f.Sections = append(f.Sections, entry)
+	f.UpdateHeaders()
+	return &f.Sections[len(f.Sections) - 1], nil
+}
+
+// Changes the size of a section, zero-filling or truncating its raw data.
+// Raw data of the following sections moves along, their addresses do not,
+// so the section may only grow up to the next one in memory. The virtual
+// size of sections with file data never shrinks.
+func (f *File) ResizeSection(index int, size uint32) error {
+	if index < 0 || index >= len(f.Sections) {
+		return fmt.Errorf("Invalid section index: %d", index)
+	}
+	entry := &f.Sections[index]
+
+	for _, other := range f.Sections {
+		if other.VirtualAddress > entry.VirtualAddress && entry.VirtualAddress + AlignUp(size, f.Windows.SectionAlign) > other.VirtualAddress {
+			return fmt.Errorf("%s: Size %x overlaps %s", entry.Name, size, other.Name)
+		}
+	}
+
+	if !entry.HasFileData() {
+		if entry.HasWatcomBssQuirk() {
+			entry.RawSize = size
+		} else {
+			entry.VirtualSize = size
+		}
+		f.UpdateHeaders()
+		return nil
+	}
+
+	oldEnd := AlignUp(entry.RawOffset + entry.RawSize, f.Windows.FileAlign)
+	newEnd := AlignUp(entry.RawOffset + size, f.Windows.FileAlign)
+	for i := range f.Sections {
+		other := &f.Sections[i]
+		if i == index || !other.HasFileData() || other.RawOffset < entry.RawOffset {
+			continue
+		}
+		other.RawOffset = other.RawOffset + newEnd - oldEnd
+	}
+	f.moveFileOffsets(entry.RawOffset + entry.RawSize, newEnd - oldEnd)
+
+	if size <= uint32(len(entry.Raw)) {
+		entry.Raw = entry.Raw[:size]
+	} else {
+		entry.Raw = append(entry.Raw, make([]byte, int(size) - len(entry.Raw))...)
+	}
+	entry.RawSize = AlignUp(size, f.Windows.FileAlign)
+	entry.VirtualSize = max(entry.VirtualSize, size)
+
+	f.UpdateHeaders()
+	return nil
+}
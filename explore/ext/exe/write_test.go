@@ -0,0 +1,204 @@
+package exe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"strings"
+	"testing"
+)
+
+// 32-bit MinGW executable from the Go debug/pe test data
+const fixture = "testdata/gcc-386-mingw-exec"
+
+func TestRoundTrip(t *testing.T) {
+	original, err := os.ReadFile(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Shrinks .data to half of its raw size, leaving a gap before .rdata
+	withGap := bytes.Clone(original)
+	binary.LittleEndian.PutUint32(withGap[0x1a0 + 16:], 0x100)
+	copy(withGap[0x1300:0x1400], bytes.Repeat([]byte("gap!"), 0x40))
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"unmodified", original},
+		{"gap between sections", withGap},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			read, err := Read(bytes.NewReader(test.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := read.Bytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(out, test.data) {
+				t.Errorf("Read and Bytes differ from the input at %x", firstDifference(out, test.data))
+			}
+
+			parsed, err := Parse(test.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err = parsed.Bytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(out, test.data) {
+				t.Errorf("Parse and Bytes differ from the input at %x", firstDifference(out, test.data))
+			}
+		})
+	}
+}
+
+func TestResizeSectionMovesGaps(t *testing.T) {
+	data, err := os.ReadFile(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	binary.LittleEndian.PutUint32(data[0x1a0 + 16:], 0x100)
+	copy(data[0x1300:0x1400], bytes.Repeat([]byte("gap!"), 0x40))
+
+	file, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Growing .text by one file alignment unit moves everything after it
+	if err := file.ResizeSection(0, 0x1000); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := file.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out[0x1500:0x1600], data[0x1300:0x1400]) {
+		t.Errorf("Gap bytes were not moved with the sections")
+	}
+}
+
+func TestUpdateHeadersKeepsLinkerSizes(t *testing.T) {
+	data := mustRead(t, fixture)
+	file, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.UpdateHeaders()
+
+	out, err := file.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, data) {
+		t.Errorf("Updated headers differ from the input at %x", firstDifference(out, data))
+	}
+}
+
+func TestAddSection(t *testing.T) {
+	data := mustRead(t, fixture)
+	file, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	symbolTable := data[file.Pe.SymbolTablePointer:]
+	sizeOfInit := file.Standard.SizeOfInit
+
+	added := bytes.Repeat([]byte("new!"), 0x50)
+	if _, err := file.AddSection(".new", added, 0x1000, SectionInitializedData | SectionRead); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.UpdateChecksum(); err != nil {
+		t.Fatal(err)
+	}
+	out, err := file.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reparsed, err := Parse(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := reparsed.Sections[len(reparsed.Sections) - 1]
+	if len(reparsed.Sections) != 16 || entry.Name != ".new" || entry.VirtualAddress != 0x10000 || entry.RawOffset != 0x3c00 || entry.RawSize != 0x200 {
+		t.Errorf("Added section is %s at %08x, raw %08x+%x", entry.Name, entry.VirtualAddress, entry.RawOffset, entry.RawSize)
+	}
+	if !bytes.Equal(entry.Raw[:len(added)], added) {
+		t.Errorf("Raw data of the added section differs")
+	}
+	if reparsed.Windows.SizeOfImage != 0x11000 || reparsed.Standard.SizeOfInit != sizeOfInit + 0x200 {
+		t.Errorf("SizeOfImage is %x, SizeOfInitializedData is %x", reparsed.Windows.SizeOfImage, reparsed.Standard.SizeOfInit)
+	}
+	if reparsed.OverlayOffset != 0x3e00 || reparsed.Pe.SymbolTablePointer != 0x3e00 || !bytes.Equal(out[reparsed.Pe.SymbolTablePointer:], symbolTable) {
+		t.Errorf("Overlay at %x, symbol table at %x", reparsed.OverlayOffset, reparsed.Pe.SymbolTablePointer)
+	}
+	if checksum, err := reparsed.ComputeChecksum(); err != nil || checksum != reparsed.Windows.CheckSum {
+		t.Errorf("Checksum is %08x, computed %08x (%v)", reparsed.Windows.CheckSum, checksum, err)
+	}
+}
+
+func TestPatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		va     uint32
+		size   int
+		errors string
+	}{
+		{"within .text", 0x401010, 16, ""},
+		{"end of .text", 0x401cd8 - 4, 4, ""},
+		{"past the end of .text", 0x401cd8, 4, "No section"},
+		{"zero-filled .bss", 0x404000, 4, "outside of the raw data"},
+		{"no section", 0x300000, 4, "No section"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			file, err := Parse(mustRead(t, fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			patch := bytes.Repeat([]byte{0xcc}, test.size)
+
+			err = file.Patch(test.va, patch)
+			if test.errors != "" {
+				if err == nil || !strings.Contains(err.Error(), test.errors) {
+					t.Fatalf("Expected an error containing %q, got %v", test.errors, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			entry, offset := file.SectionByRVA(test.va - file.Windows.ImageBase)
+			if !bytes.Equal(entry.Raw[offset:][:test.size], patch) {
+				t.Errorf("Patch is not applied")
+			}
+		})
+	}
+}
+
+func mustRead(t *testing.T, path string) []byte {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func firstDifference(a, b []byte) int {
+	for i := range min(len(a), len(b)) {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return min(len(a), len(b))
+}
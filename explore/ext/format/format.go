@@ -0,0 +1,161 @@
+package format
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/dexter3k/watre/explore/ext/exe"
+	"github.com/dexter3k/watre/explore/ext/le"
+	"github.com/dexter3k/watre/explore/ext/mz"
+	"github.com/dexter3k/watre/explore/ext/ne"
+)
+
+type Kind int
+const (
+	KindUnknown Kind = iota
+	KindMZ
+	KindNE
+	KindLE
+	KindLX
+	KindPE
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindUnknown:
+		return "unknown"
+	case KindMZ:
+		return "MZ"
+	case KindNE:
+		return "NE"
+	case KindLE:
+		return "LE"
+	case KindLX:
+		return "LX"
+	case KindPE:
+		return "PE"
+	default:
+		return fmt.Sprintf("Kind(%d)", int(k))
+	}
+}
+
+// Anything that can be mapped into a flat address space
+type Executable interface {
+	Image() (*exe.Image, error)
+}
+
+// Sniffs the executable format by the signature found behind the DOS header
+func Detect(r io.ReadSeeker) (Kind, error) {
+	order := binary.LittleEndian
+
+	if _, err := r.Seek(0, 0); err != nil {
+		return KindUnknown, err
+	}
+
+	var dos exe.DosHeader
+	if err := binary.Read(r, order, &dos); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return KindUnknown, nil
+		}
+		return KindUnknown, err
+	}
+	if dos.Magic != 0x5a4d && dos.Magic != 0x4d5a {
+		return KindUnknown, nil
+	}
+	// DOS loads ZM programs too, but Windows and the extenders only follow
+	// the new header of MZ ones
+	if dos.Magic == 0x4d5a {
+		return KindMZ, nil
+	}
+
+	// Plain DOS programs leave the new header offset as garbage,
+	// so a missing signature there just means this is an MZ
+	if dos.PeHeaderOffset < 0x40 {
+		return KindMZ, nil
+	}
+	if _, err := r.Seek(int64(dos.PeHeaderOffset), 0); err != nil {
+		return KindMZ, nil
+	}
+
+	var signature [4]byte
+	if _, err := io.ReadFull(r, signature[:]); err != nil {
+		return KindMZ, nil
+	}
+
+	switch {
+	case order.Uint32(signature[:]) == 0x4550:
+		return KindPE, nil
+	case order.Uint16(signature[:]) == ne.Signature:
+		return KindNE, nil
+	case order.Uint16(signature[:]) == le.SignatureLE:
+		return KindLE, nil
+	case order.Uint16(signature[:]) == le.SignatureLX:
+		return KindLX, nil
+	default:
+		return KindMZ, nil
+	}
+}
+
+// Reads the executable with the reader matching its format.
+// The returned value is one of *exe.File, *le.File, *ne.File or *mz.File.
+// The whole file is read into memory, overlay included; map it and use
+// Parse to only page in what is accessed.
+func Open(r io.ReadSeeker) (Executable, Kind, error) {
+	kind, err := Detect(r)
+	if err != nil {
+		return nil, kind, err
+	}
+
+	if _, err := r.Seek(0, 0); err != nil {
+		return nil, kind, err
+	}
+
+	var file Executable
+	switch kind {
+	case KindPE:
+		file, err = exe.Read(r)
+	case KindNE:
+		file, err = ne.Read(r)
+	case KindLE, KindLX:
+		file, err = le.Read(r)
+	case KindMZ:
+		file, err = mz.Read(r)
+	default:
+		return nil, kind, fmt.Errorf("Unknown executable format")
+	}
+	if err != nil {
+		return nil, kind, err
+	}
+
+	return file, kind, nil
+}
+
+// Like Open, but parses data in place where the format reader allows it.
+// PE files are parsed without their debug info, see exe.Parse.
+func Parse(data []byte) (Executable, Kind, error) {
+	kind, err := Detect(bytes.NewReader(data))
+	if err != nil {
+		return nil, kind, err
+	}
+
+	var file Executable
+	switch kind {
+	case KindPE:
+		file, err = exe.Parse(data)
+	case KindNE:
+		file, err = ne.Parse(data)
+	case KindLE, KindLX:
+		file, err = le.Parse(data)
+	case KindMZ:
+		file, err = mz.Parse(data)
+	default:
+		return nil, kind, fmt.Errorf("Unknown executable format")
+	}
+	if err != nil {
+		return nil, kind, err
+	}
+
+	return file, kind, nil
+}
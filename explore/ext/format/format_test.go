@@ -0,0 +1,56 @@
+package format
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/dexter3k/watre/explore/ext/exe"
+)
+
+// DOS header with the given magic, pointing at a new header with the given signature
+func dosWithSignature(magic uint16, signature []byte) []byte {
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, exe.DosHeader{Magic: magic, PeHeaderOffset: 0x40})
+	b.Write(signature)
+	b.Write(make([]byte, 0x100))
+	return b.Bytes()
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		kind Kind
+	}{
+		{"empty", nil, KindUnknown},
+		{"not an executable", []byte("#!/bin/sh\n"), KindUnknown},
+		{"PE", dosWithSignature(0x5a4d, []byte("PE\x00\x00")), KindPE},
+		{"LE", dosWithSignature(0x5a4d, []byte("LE")), KindLE},
+		{"LX", dosWithSignature(0x5a4d, []byte("LX")), KindLX},
+		{"NE", dosWithSignature(0x5a4d, []byte("NE")), KindNE},
+		{"MZ with garbage behind the DOS header", dosWithSignature(0x5a4d, []byte("??")), KindMZ},
+		// Only DOS itself loads ZM programs, the new header is never followed
+		{"ZM with an LE header", dosWithSignature(0x4d5a, []byte("LE")), KindMZ},
+		{"ZM with a PE header", dosWithSignature(0x4d5a, []byte("PE\x00\x00")), KindMZ},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			kind, err := Detect(bytes.NewReader(test.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if kind != test.kind {
+				t.Fatalf("Detected %s, expected %s", kind, test.kind)
+			}
+		})
+	}
+}
+
+// Whatever Detect routes a ZM file to must accept it
+func TestParseZM(t *testing.T) {
+	if _, kind, err := Parse(dosWithSignature(0x4d5a, []byte("LE"))); err != nil {
+		t.Fatalf("Parsing as %s: %v", kind, err)
+	}
+}
@@ -0,0 +1,505 @@
+package le
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/dexter3k/watre/explore/ext/exe"
+)
+
+var ErrNotLinearExecutable = errors.New("not an LE/LX executable")
+
+func Read(r io.ReadSeeker) (*File, error) {
+	if _, err := r.Seek(0, 0); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse(data)
+}
+
+func Parse(data []byte) (*File, error) {
+	le := binary.LittleEndian
+
+	file := &File{
+		data: data,
+	}
+
+	if err := binary.Read(bytes.NewReader(data), le, &file.Dos); err != nil {
+		return nil, err
+	}
+	if file.Dos.Magic != 0x5a4d {
+		return nil, fmt.Errorf("Invalid DOS Header Magic: %04x", file.Dos.Magic)
+	}
+
+	file.HeaderOffset = file.Dos.PeHeaderOffset
+	if uint64(file.HeaderOffset) + 2 > uint64(len(data)) {
+		return nil, ErrNotLinearExecutable
+	}
+	if signature := le.Uint16(data[file.HeaderOffset:]); signature != SignatureLE && signature != SignatureLX {
+		return nil, ErrNotLinearExecutable
+	}
+
+	header := data[file.HeaderOffset:]
+	if err := binary.Read(bytes.NewReader(header), le, &file.Header); err != nil {
+		return nil, err
+	}
+	h := &file.Header
+	if h.ByteOrder != 0 || h.WordOrder != 0 {
+		return nil, fmt.Errorf("Big endian LE/LX executables are not supported")
+	}
+	if h.PageSize == 0 {
+		return nil, fmt.Errorf("Invalid LE/LX page size")
+	}
+
+	// Sizes are computed from untrusted counts, so in 64 bits, where they cannot wrap
+	sub := func(offset, size uint64) ([]byte, error) {
+		if offset + size > uint64(len(header)) {
+			return nil, fmt.Errorf("LE/LX table is out of bounds: %08x+%x", offset, size)
+		}
+		return header[offset:][:size], nil
+	}
+
+	// Object table
+	objects, err := sub(uint64(h.ObjectTableOffset), uint64(h.ObjectCount) * 24)
+	if err != nil {
+		return nil, err
+	}
+	file.Objects = make([]Object, h.ObjectCount)
+	if err := binary.Read(bytes.NewReader(objects), le, file.Objects); err != nil {
+		return nil, err
+	}
+
+	// Object page map
+	entrySize := uint64(4)
+	if h.IsLX() {
+		entrySize = 8
+	}
+	pageMap, err := sub(uint64(h.PageMapOffset), uint64(h.PageCount) * entrySize)
+	if err != nil {
+		return nil, err
+	}
+	for i := uint32(0); i < h.PageCount; i++ {
+		entry := pageMap[uint64(i) * entrySize:]
+
+		var page Page
+		if h.IsLX() {
+			page.Flags = PageFlags(le.Uint16(entry[6:]))
+			page.Size = uint32(le.Uint16(entry[4:]))
+
+			base := h.DataPagesOffset
+			if page.Flags == PageIterated && h.IteratedMapOffset != 0 {
+				base = h.IteratedMapOffset
+			}
+			page.Offset = base + le.Uint32(entry) << h.LastPageSizeOrShift
+		} else {
+			number := uint32(entry[0]) << 16 | uint32(entry[1]) << 8 | uint32(entry[2])
+			page.Flags = PageFlags(entry[3])
+			page.Size = h.PageSize
+			if number == h.PageCount {
+				page.Size = h.LastPageSizeOrShift
+			}
+			if number > 0 {
+				page.Offset = h.DataPagesOffset + (number - 1) * h.PageSize
+			}
+		}
+
+		file.Pages = append(file.Pages, page)
+	}
+
+	// Fixup page table has one extra entry, marking the end of the last page's records
+	if h.FixupPageTableOffset != 0 {
+		pageTable, err := sub(uint64(h.FixupPageTableOffset), (uint64(h.PageCount) + 1) * 4)
+		if err != nil {
+			return nil, err
+		}
+
+		file.Fixups = make([][]Fixup, h.PageCount)
+		for i := uint32(0); i < h.PageCount; i++ {
+			start := le.Uint32(pageTable[i * 4:])
+			end := le.Uint32(pageTable[i * 4 + 4:])
+			if end < start {
+				return nil, fmt.Errorf("Fixup page table is not sorted at page %d", i + 1)
+			}
+
+			records, err := sub(uint64(h.FixupRecordsOffset) + uint64(start), uint64(end - start))
+			if err != nil {
+				return nil, err
+			}
+
+			fixups, err := parseFixups(records)
+			if err != nil {
+				return nil, fmt.Errorf("Page %d: %w", i + 1, err)
+			}
+			file.Fixups[i] = fixups
+		}
+	}
+
+	if h.EntryTableOffset != 0 {
+		if h.EntryTableOffset >= uint32(len(header)) {
+			return nil, fmt.Errorf("LE/LX entry table is out of bounds")
+		}
+		entries, err := parseEntries(header[h.EntryTableOffset:])
+		if err != nil {
+			return nil, err
+		}
+		file.Entries = entries
+	}
+
+	if h.ResidentNamesOffset != 0 && h.ResidentNamesOffset < uint32(len(header)) {
+		file.ResidentNames = parseNames(header[h.ResidentNamesOffset:])
+	}
+
+	if h.NonResidentNamesOffset != 0 && uint64(h.NonResidentNamesOffset) + uint64(h.NonResidentNamesLength) <= uint64(len(data)) {
+		file.NonResidentNames = parseNames(data[h.NonResidentNamesOffset:][:h.NonResidentNamesLength])
+	}
+
+	return file, nil
+}
+
+func parseFixups(data []byte) ([]Fixup, error) {
+	le := binary.LittleEndian
+
+	var fixups []Fixup
+	for len(data) > 0 {
+		if len(data) < 2 {
+			return nil, fmt.Errorf("Truncated fixup record")
+		}
+
+		source := FixupSource(data[0])
+		flags := data[1]
+		data = data[2:]
+
+		fixup := Fixup{
+			Source: source & fixupSourceTypeMask,
+			Target: FixupTarget(flags & 3),
+		}
+
+		// All reads below may run out of data on corrupt records
+		short := false
+		take := func(n int) []byte {
+			if len(data) < n {
+				short = true
+				return make([]byte, n)
+			}
+			res := data[:n]
+			data = data[n:]
+			return res
+		}
+		index := func() uint16 {
+			if flags & 0x40 != 0 {
+				return le.Uint16(take(2))
+			}
+			return uint16(take(1)[0])
+		}
+		offset := func(wide bool) uint32 {
+			if wide {
+				return le.Uint32(take(4))
+			}
+			return uint32(le.Uint16(take(2)))
+		}
+
+		sourceCount := 1
+		if source & fixupSourceList != 0 {
+			sourceCount = int(take(1)[0])
+		} else {
+			fixup.SourceOffsets = []int16{int16(le.Uint16(take(2)))}
+		}
+
+		switch fixup.Target {
+		case FixupTargetInternal:
+			fixup.Object = index()
+			if fixup.Source != FixupSourceSelector16 {
+				fixup.TargetOffset = offset(flags & 0x10 != 0)
+			}
+		case FixupTargetImportOrdinal:
+			fixup.Module = index()
+			if flags & 0x80 != 0 {
+				fixup.Ordinal = uint32(take(1)[0])
+			} else {
+				fixup.Ordinal = offset(flags & 0x10 != 0)
+			}
+		case FixupTargetImportName:
+			fixup.Module = index()
+			fixup.NameOffset = offset(flags & 0x10 != 0)
+		case FixupTargetInternalByEntry:
+			fixup.Ordinal = uint32(index())
+		}
+
+		if fixup.Target != FixupTargetInternal && flags & 0x04 != 0 {
+			fixup.Additive = offset(flags & 0x20 != 0)
+		}
+
+		if source & fixupSourceList != 0 {
+			for i := 0; i < sourceCount; i++ {
+				fixup.SourceOffsets = append(fixup.SourceOffsets, int16(le.Uint16(take(2))))
+			}
+		}
+
+		if short {
+			return nil, fmt.Errorf("Truncated fixup record")
+		}
+
+		fixups = append(fixups, fixup)
+	}
+
+	return fixups, nil
+}
+
+func parseEntries(data []byte) ([]Entry, error) {
+	le := binary.LittleEndian
+
+	var entries []Entry
+	ordinal := uint16(1)
+	for len(data) > 0 && data[0] != 0 {
+		if len(data) < 2 {
+			return nil, fmt.Errorf("Truncated entry table")
+		}
+
+		count := int(data[0])
+		kind := EntryType(data[1])
+		data = data[2:]
+
+		if kind == EntryUnused {
+			ordinal += uint16(count)
+			continue
+		}
+
+		if len(data) < 2 {
+			return nil, fmt.Errorf("Truncated entry table")
+		}
+		object := le.Uint16(data)
+		data = data[2:]
+
+		var size int
+		switch kind {
+		case Entry16Bit:
+			size = 3
+		case EntryCallGate:
+			size = 5
+		case Entry32Bit:
+			size = 5
+		case EntryForwarder:
+			size = 7
+		default:
+			return nil, fmt.Errorf("Unknown entry bundle type: %d", kind)
+		}
+		if len(data) < size * count {
+			return nil, fmt.Errorf("Truncated entry table")
+		}
+
+		for i := 0; i < count; i++ {
+			entry := Entry{
+				Ordinal: ordinal,
+				Type:    kind,
+				Flags:   data[0],
+				Object:  object,
+			}
+
+			switch kind {
+			case Entry16Bit:
+				entry.Offset = uint32(le.Uint16(data[1:]))
+			case EntryCallGate:
+				entry.Offset = uint32(le.Uint16(data[1:]))
+				entry.Extra = le.Uint16(data[3:])
+			case Entry32Bit:
+				entry.Offset = le.Uint32(data[1:])
+			case EntryForwarder:
+				entry.Object = 0
+				entry.Extra = le.Uint16(data[1:])
+				entry.Offset = le.Uint32(data[3:])
+			}
+
+			entries = append(entries, entry)
+			data = data[size:]
+			ordinal++
+		}
+	}
+
+	return entries, nil
+}
+
+func parseNames(data []byte) []Name {
+	var names []Name
+	for len(data) > 0 && data[0] != 0 {
+		size := int(data[0])
+		if len(data) < size + 3 {
+			break
+		}
+
+		names = append(names, Name{
+			Name:    string(data[1:][:size]),
+			Ordinal: binary.LittleEndian.Uint16(data[1 + size:]),
+		})
+		data = data[size + 3:]
+	}
+
+	return names
+}
+
+// Returns the contents of a page, expanding iterated pages. Invalid and
+// zero-filled pages are returned as zeroes.
+func (f *File) PageData(page int) ([]byte, error) {
+	if page < 0 || page >= len(f.Pages) {
+		return nil, fmt.Errorf("Page index is out of range: %d", page)
+	}
+
+	entry := f.Pages[page]
+	switch entry.Flags {
+	case PageLegal, PageIterated:
+	case PageInvalid, PageZeroFilled:
+		return make([]byte, f.Header.PageSize), nil
+	default:
+		return nil, fmt.Errorf("Unsupported page type: %d", entry.Flags)
+	}
+
+	if uint64(entry.Offset) + uint64(entry.Size) > uint64(len(f.data)) {
+		return nil, fmt.Errorf("Page %d is out of file bounds", page + 1)
+	}
+	raw := f.data[entry.Offset:][:entry.Size]
+
+	if entry.Flags == PageLegal {
+		return raw, nil
+	}
+
+	// Iterated pages consist of (count, length, data) records
+	le := binary.LittleEndian
+	var result []byte
+	for len(raw) >= 4 {
+		count := int(le.Uint16(raw))
+		length := int(le.Uint16(raw[2:]))
+		raw = raw[4:]
+		if count == 0 || length > len(raw) {
+			break
+		}
+
+		for i := 0; i < count; i++ {
+			result = append(result, raw[:length]...)
+		}
+		raw = raw[length:]
+	}
+
+	return result, nil
+}
+
+// Maps all objects into their virtual addresses and applies internal fixups,
+// producing the image as DOS/4GW would see it after loading
+func (f *File) Image() (*exe.Image, error) {
+	img := &exe.Image{}
+
+	contents := make([][]byte, len(f.Objects))
+	for i, object := range f.Objects {
+		data := make([]byte, 0, object.VirtualSize)
+
+		// Trailing zero-filled pages are left to the virtual tail of the object
+		backed := 0
+		for j := uint32(0); j < object.PageCount; j++ {
+			page := int(object.PageTableIndex + j) - 1
+			if page < 0 || page >= len(f.Pages) {
+				return nil, fmt.Errorf("Object %d refers to invalid page %d", i + 1, page + 1)
+			}
+			if f.Pages[page].Flags == PageLegal || f.Pages[page].Flags == PageIterated {
+				backed = int(j) + 1
+			}
+		}
+
+		var offset uint32
+		for j := 0; j < backed; j++ {
+			page := int(object.PageTableIndex) + j - 1
+			pageData, err := f.PageData(page)
+			if err != nil {
+				return nil, err
+			}
+			if j == 0 {
+				offset = f.Pages[page].Offset
+			}
+
+			data = append(data, pageData...)
+			if pad := (j + 1) * int(f.Header.PageSize) - len(data); pad > 0 && j + 1 < backed {
+				data = append(data, make([]byte, pad)...)
+			}
+		}
+		if uint32(len(data)) > object.VirtualSize {
+			data = data[:object.VirtualSize]
+		}
+
+		contents[i] = data
+		img.Sections = append(img.Sections, exe.ImageSection{
+			Name:            fmt.Sprintf("OBJ%d", i + 1),
+			Address:         object.BaseAddress,
+			Size:            object.VirtualSize,
+			Characteristics: object.Flags.Characteristics(),
+			Offset:          offset,
+			Data:            data,
+		})
+	}
+
+	if err := f.applyFixups(contents); err != nil {
+		return nil, err
+	}
+
+	return img, nil
+}
+
+func (f *File) applyFixups(contents [][]byte) error {
+	le := binary.LittleEndian
+
+	for i, object := range f.Objects {
+		data := contents[i]
+
+		for j := uint32(0); j < object.PageCount; j++ {
+			page := int(object.PageTableIndex + j) - 1
+			if page >= len(f.Fixups) {
+				continue
+			}
+
+			for _, fixup := range f.Fixups[page] {
+				if fixup.Target != FixupTargetInternal {
+					continue
+				}
+				if fixup.Object == 0 || int(fixup.Object) > len(f.Objects) {
+					return fmt.Errorf("Fixup refers to invalid object %d", fixup.Object)
+				}
+				target := f.Objects[fixup.Object - 1].BaseAddress + fixup.TargetOffset
+
+				for _, sourceOffset := range fixup.SourceOffsets {
+					source := int(j) * int(f.Header.PageSize) + int(sourceOffset)
+					sourceAddress := object.BaseAddress + uint32(source)
+
+					var value [4]byte
+					var size int
+					switch fixup.Source {
+					case FixupSourceOffset32, FixupSourcePointer48:
+						le.PutUint32(value[:], target)
+						size = 4
+					case FixupSourceRelative32:
+						le.PutUint32(value[:], target - sourceAddress - 4)
+						size = 4
+					case FixupSourceOffset16:
+						le.PutUint16(value[:], uint16(target))
+						size = 2
+					default:
+						// Selectors are assigned by the extender at runtime
+						continue
+					}
+
+					// Fixups crossing page boundaries are listed for both pages,
+					// so writing just the part that fits is enough
+					for k := 0; k < size; k++ {
+						if source + k >= 0 && source + k < len(data) {
+							data[source + k] = value[k]
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,137 @@
+package le
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/dexter3k/watre/explore/ext/exe"
+)
+
+// Builds a single object, single page LE with one internal fixup,
+// letting the test break the header before it is written
+func buildLE(t *testing.T, mutate func(h *Header)) []byte {
+	le := binary.LittleEndian
+	headerSize := uint32(binary.Size(Header{}))
+
+	fixups := []byte{
+		byte(FixupSourceOffset32), byte(FixupTargetInternal),
+		4, 0, // source offset
+		1,          // object
+		0x10, 0x00, // target offset
+	}
+	pageData := []byte{0x90, 0x90, 0x90, 0x90, 0, 0, 0, 0}
+
+	h := Header{
+		Signature:           SignatureLE,
+		PageCount:           1,
+		PageSize:            0x1000,
+		LastPageSizeOrShift: uint32(len(pageData)),
+		ObjectCount:         1,
+	}
+	h.ObjectTableOffset = headerSize
+	h.PageMapOffset = h.ObjectTableOffset + 24
+	h.FixupPageTableOffset = h.PageMapOffset + 4
+	h.FixupRecordsOffset = h.FixupPageTableOffset + 8
+	h.DataPagesOffset = 0x40 + h.FixupRecordsOffset + uint32(len(fixups))
+	if mutate != nil {
+		mutate(&h)
+	}
+
+	var b bytes.Buffer
+	check := func(err error) {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	check(binary.Write(&b, le, exe.DosHeader{Magic: 0x5a4d, PeHeaderOffset: 0x40}))
+	check(binary.Write(&b, le, h))
+	check(binary.Write(&b, le, Object{
+		VirtualSize:    0x20,
+		BaseAddress:    0x10000,
+		Flags:          ObjectReadable | ObjectExecutable,
+		PageTableIndex: 1,
+		PageCount:      1,
+	}))
+	b.Write([]byte{0, 0, 1, byte(PageLegal)})
+	check(binary.Write(&b, le, [2]uint32{0, uint32(len(fixups))}))
+	b.Write(fixups)
+	b.Write(pageData)
+
+	return b.Bytes()
+}
+
+func TestParse(t *testing.T) {
+	file, err := Parse(buildLE(t, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(file.Objects) != 1 || len(file.Pages) != 1 || len(file.Fixups[0]) != 1 {
+		t.Fatalf("Got %d objects, %d pages, %v fixups", len(file.Objects), len(file.Pages), file.Fixups)
+	}
+
+	img, err := file.Image()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := img.Sections[0].Data
+	if len(data) != 8 {
+		t.Fatalf("Object data is %d bytes long", len(data))
+	}
+	if got := binary.LittleEndian.Uint32(data[4:]); got != 0x10010 {
+		t.Errorf("Fixup wrote %08x, expected 00010010", got)
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   func() []byte
+		errors string
+	}{
+		{"reversed magic", func() []byte {
+			data := buildLE(t, nil)
+			data[0], data[1] = 'Z', 'M'
+			return data
+		}, "Invalid DOS Header Magic"},
+		{"truncated header", func() []byte {
+			return buildLE(t, nil)[:0x50]
+		}, "EOF"},
+		{"object count wrapping to a small size", func() []byte {
+			return buildLE(t, func(h *Header) {
+				h.ObjectCount = 0x0aaaaaab // * 24 wraps to 8 in 32 bits
+			})
+		}, "out of bounds"},
+		{"page count wrapping to zero", func() []byte {
+			return buildLE(t, func(h *Header) {
+				h.PageCount = 0x40000000
+			})
+		}, "out of bounds"},
+		{"fixup page table wrapping to zero", func() []byte {
+			return buildLE(t, func(h *Header) {
+				h.PageCount = 0xffffffff
+				h.PageMapOffset = 0
+			})
+		}, "out of bounds"},
+		{"fixup records past the end", func() []byte {
+			return buildLE(t, func(h *Header) {
+				h.FixupRecordsOffset = 0xfffffff0
+			})
+		}, "out of bounds"},
+		{"zero page size", func() []byte {
+			return buildLE(t, func(h *Header) {
+				h.PageSize = 0
+			})
+		}, "page size"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse(test.data())
+			if err == nil || !strings.Contains(err.Error(), test.errors) {
+				t.Fatalf("Expected an error containing %q, got %v", test.errors, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package le
+
+import (
+	"github.com/dexter3k/watre/explore/ext/exe"
+)
+
+const (
+	SignatureLE = 0x454c
+	SignatureLX = 0x584c
+)
+
+type Header struct {
+	Signature   uint16
+	ByteOrder   uint8
+	WordOrder   uint8
+	Level       uint32
+	Cpu         uint16
+	Os          uint16
+	Version     uint32
+	ModuleFlags uint32
+	PageCount   uint32
+
+	EipObject uint32
+	Eip       uint32
+	EspObject uint32
+	Esp       uint32
+
+	PageSize uint32
+	// LE: number of bytes on the last page, LX: page offset shift
+	LastPageSizeOrShift uint32
+
+	FixupSectionSize      uint32
+	FixupSectionChecksum  uint32
+	LoaderSectionSize     uint32
+	LoaderSectionChecksum uint32
+
+	// Offsets below are relative to the LE header, unless noted
+	ObjectTableOffset    uint32
+	ObjectCount          uint32
+	PageMapOffset        uint32
+	IteratedMapOffset    uint32
+	ResourceTableOffset  uint32
+	ResourceCount        uint32
+	ResidentNamesOffset  uint32
+	EntryTableOffset     uint32
+	DirectivesOffset     uint32
+	DirectivesCount      uint32
+	FixupPageTableOffset uint32
+	FixupRecordsOffset   uint32
+	ImportModulesOffset  uint32
+	ImportModulesCount   uint32
+	ImportProcsOffset    uint32
+	PageChecksumsOffset  uint32
+
+	// Relative to the start of the file
+	DataPagesOffset uint32
+	PreloadPages    uint32
+	// Relative to the start of the file
+	NonResidentNamesOffset   uint32
+	NonResidentNamesLength   uint32
+	NonResidentNamesChecksum uint32
+
+	AutoDataObject uint32
+	// Relative to the start of the file
+	DebugInfoOffset uint32
+	DebugInfoLength uint32
+
+	PreloadInstancePages uint32
+	DemandInstancePages  uint32
+	ExtraHeap            uint32
+}
+
+func (h *Header) IsLX() bool {
+	return h.Signature == SignatureLX
+}
+
+type ObjectFlags uint32
+const (
+	ObjectReadable    ObjectFlags = 0x0001
+	ObjectWritable    ObjectFlags = 0x0002
+	ObjectExecutable  ObjectFlags = 0x0004
+	ObjectResource    ObjectFlags = 0x0008
+	ObjectDiscardable ObjectFlags = 0x0010
+	ObjectShared      ObjectFlags = 0x0020
+	ObjectPreload     ObjectFlags = 0x0040
+	ObjectInvalid     ObjectFlags = 0x0080
+	ObjectZeroFilled  ObjectFlags = 0x0100
+	ObjectResident    ObjectFlags = 0x0200
+	ObjectAlias1616   ObjectFlags = 0x1000
+	ObjectBig         ObjectFlags = 0x2000
+	ObjectConforming  ObjectFlags = 0x4000
+	ObjectIoPrivilege ObjectFlags = 0x8000
+)
+
+func (f ObjectFlags) Has(flags ObjectFlags) bool {
+	return f & flags == flags
+}
+
+// Translates object flags into equivalent PE section characteristics
+func (f ObjectFlags) Characteristics() exe.SectionCharacteristics {
+	var c exe.SectionCharacteristics
+	if f.Has(ObjectReadable) {
+		c |= exe.SectionRead
+	}
+	if f.Has(ObjectWritable) {
+		c |= exe.SectionWrite
+	}
+	if f.Has(ObjectExecutable) {
+		c |= exe.SectionExecute | exe.SectionCode
+	} else {
+		c |= exe.SectionInitializedData
+	}
+	if f.Has(ObjectDiscardable) {
+		c |= exe.SectionDiscardable
+	}
+	if f.Has(ObjectShared) {
+		c |= exe.SectionShared
+	}
+	return c
+}
+
+type Object struct {
+	VirtualSize    uint32
+	BaseAddress    uint32
+	Flags          ObjectFlags
+	PageTableIndex uint32
+	PageCount      uint32
+	Reserved       uint32
+}
+
+type PageFlags uint16
+const (
+	PageLegal      PageFlags = 0
+	PageIterated   PageFlags = 1
+	PageInvalid    PageFlags = 2
+	PageZeroFilled PageFlags = 3
+	PageRange      PageFlags = 4
+	PageIterated2  PageFlags = 5
+)
+
+type Page struct {
+	// Absolute file offset of the page data
+	Offset uint32
+	Size   uint32
+	Flags  PageFlags
+}
+
+type FixupSource uint8
+const (
+	FixupSourceByte       FixupSource = 0
+	FixupSourceSelector16 FixupSource = 2
+	FixupSourcePointer32  FixupSource = 3
+	FixupSourceOffset16   FixupSource = 5
+	FixupSourcePointer48  FixupSource = 6
+	FixupSourceOffset32   FixupSource = 7
+	FixupSourceRelative32 FixupSource = 8
+
+	fixupSourceTypeMask FixupSource = 0x0f
+	fixupSourceAlias    FixupSource = 0x10
+	fixupSourceList     FixupSource = 0x20
+)
+
+type FixupTarget uint8
+const (
+	FixupTargetInternal        FixupTarget = 0
+	FixupTargetImportOrdinal   FixupTarget = 1
+	FixupTargetImportName      FixupTarget = 2
+	FixupTargetInternalByEntry FixupTarget = 3
+)
+
+type Fixup struct {
+	Source FixupSource
+	Target FixupTarget
+	// Offsets within the page, may be negative or cross into the next page
+	SourceOffsets []int16
+
+	// Internal targets
+	Object       uint16
+	TargetOffset uint32
+
+	// Imports and entry table targets
+	Module     uint16
+	Ordinal    uint32
+	NameOffset uint32
+
+	Additive uint32
+}
+
+type EntryType uint8
+const (
+	EntryUnused    EntryType = 0
+	Entry16Bit     EntryType = 1
+	EntryCallGate  EntryType = 2
+	Entry32Bit     EntryType = 3
+	EntryForwarder EntryType = 4
+)
+
+type Entry struct {
+	Ordinal uint16
+	Type    EntryType
+	Flags   uint8
+	Object  uint16
+	Offset  uint32
+	// Call gate selector or forwarder module ordinal
+	Extra uint16
+}
+
+type Name struct {
+	Name    string
+	Ordinal uint16
+}
+
+type File struct {
+	Dos          exe.DosHeader
+	HeaderOffset uint32
+	Header       Header
+
+	Objects []Object
+	// Indexed by zero-based page number
+	Pages  []Page
+	Fixups [][]Fixup
+
+	Entries          []Entry
+	ResidentNames    []Name
+	NonResidentNames []Name
+
+	data []byte
+}
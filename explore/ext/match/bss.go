@@ -0,0 +1,243 @@
+package match
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+
+	"github.com/dexter3k/watre/explore/ext/exe"
+	"github.com/dexter3k/watre/explore/ext/omf"
+)
+
+// BSS segments have no bytes to match, they are placed from relocations that
+// point into them and, failing that, after the BSS of the preceding object
+// in the link order, as long as no unmatched code sits between the two.
+
+// Where the address of a BSS segment comes from
+type StaticSource string
+const (
+	// Placed segments refer into it
+	StaticSourceRelocation StaticSource = "relocation"
+	// It follows the BSS of the preceding object
+	StaticSourceLinkOrder StaticSource = ConfidenceLinkOrder
+)
+
+// A variable within a BSS segment
+type StaticSymbol struct {
+	// Empty for variables only known from relocations
+	Name    string
+	Address uint32
+	// Up to the next known variable or the end of the segment
+	Size uint32
+}
+
+type StaticSegment struct {
+	Object    string
+	Segment   string
+	Address   uint32
+	Size      uint32
+	Alignment uint32
+	Source    StaticSource
+	Symbols   []StaticSymbol
+}
+
+// Offset within a BSS segment some placed segment refers to
+type staticReference struct {
+	segment symbolID
+	offset  uint32
+	from    string
+}
+
+func (s *solver) isStatic(id symbolID) bool {
+	info := s.symbols.segments[id]
+	return s.symbols.local[id] && info != nil && info.location == omf.LocationStatic
+}
+
+// References to BSS segments from all placed segments, through locals and through exported globals
+func (s *solver) staticReferences() []staticReference {
+	var references []staticReference
+	for _, i := range s.intervals {
+		info := s.symbols.segments[i.symbol]
+		if info.location == omf.LocationStatic {
+			continue
+		}
+
+		for _, reloc := range info.relocs {
+			reference := staticReference{
+				from: fmt.Sprintf("%s+%x", s.symbols.names[info.id], reloc.offset),
+			}
+			if s.isStatic(reloc.symbol) {
+				reference.segment = reloc.symbol
+				reference.offset = reloc.addend
+			} else if export := s.symbols.exporters[reloc.symbol]; !s.symbols.local[reloc.symbol] && export.symbol != noSymbol && s.isStatic(export.symbol) {
+				reference.segment = export.symbol
+				reference.offset = export.offset + reloc.addend
+			} else {
+				continue
+			}
+			references = append(references, reference)
+		}
+	}
+
+	return references
+}
+
+// Places BSS segments of placed objects that nothing refers to, right after the
+// BSS of the object whose code directly precedes theirs. Returns the placed segments
+// along with the places where relocations, sizes, alignment and order disagree.
+func (s *solver) placeStatics() ([]StaticSegment, []string) {
+	var issues []string
+	source := map[symbolID]StaticSource{}
+
+	references := s.staticReferences()
+	for _, reference := range references {
+		source[reference.segment] = StaticSourceRelocation
+	}
+
+	varOf := map[*omf.Object]int{}
+	for index, v := range s.vars {
+		if v.placed {
+			varOf[v.source] = index
+		}
+	}
+
+	// Walk the BSS in link order, predicting where each segment starts.
+	// Placing statics adds intervals, so walk a copy.
+	var cursor uint32
+	var previous symbolID = noSymbol
+	var codeEnd uint32
+	for _, i := range slices.Clone(s.intervals) {
+		info := s.symbols.segments[i.symbol]
+		if info.location != omf.LocationText {
+			continue
+		}
+
+		// Unmatched code before the object may come with BSS of its own,
+		// so nothing is predicted across it
+		follows := codeEnd != 0 && exe.AlignUp(codeEnd, info.segment.Alignment) == i.start
+		codeEnd = i.end
+
+		index, found := varOf[info.object]
+		if !found || info != s.firstCode(info.object) {
+			continue
+		}
+
+		for _, segment := range info.object.Segments[omf.LocationStatic] {
+			static := s.symbols.infos[segment]
+			size := uint32(len(segment.Data))
+			alignment := max(segment.Alignment, 1)
+
+			address, placed := s.st.value(static.id)
+			if !placed && previous != noSymbol && follows {
+				address = exe.AlignUp(cursor, alignment)
+				candidate := singleObjectValidMatch{
+					bindings: []binding{{static.id, address}},
+				}
+				for _, export := range static.exports {
+					candidate.bindings = append(candidate.bindings, binding{export.symbol, address + export.offset})
+				}
+
+				if ok, reason := s.try(index, candidate); ok {
+					placed = true
+					source[static.id] = StaticSourceLinkOrder
+				} else {
+					issues = append(issues, fmt.Sprintf("%s predicted at %08x after %s: %s", s.symbols.names[static.id], address, s.symbols.names[previous], reason))
+				}
+			}
+			if !placed {
+				continue
+			}
+
+			if address % alignment != 0 {
+				issues = append(issues, fmt.Sprintf("%s at %08x is not aligned to %x", s.symbols.names[static.id], address, alignment))
+			}
+			if previous != noSymbol && address < cursor {
+				issues = append(issues, fmt.Sprintf("%s at %08x starts before the end of %s at %08x, which precedes it in the link order", s.symbols.names[static.id], address, s.symbols.names[previous], cursor))
+			}
+
+			cursor = max(cursor, address + size)
+			previous = static.id
+		}
+	}
+
+	offsets := map[symbolID][]uint32{}
+	for _, reference := range references {
+		size := uint32(len(s.symbols.segments[reference.segment].segment.Data))
+		if reference.offset > size {
+			issues = append(issues, fmt.Sprintf("%s+%x referenced from %s is past the end of the segment at %x", s.symbols.names[reference.segment], reference.offset, reference.from, size))
+			continue
+		}
+		if reference.offset < size {
+			offsets[reference.segment] = append(offsets[reference.segment], reference.offset)
+		}
+	}
+
+	var statics []StaticSegment
+	for _, i := range s.intervals {
+		info := s.symbols.segments[i.symbol]
+		if info.location != omf.LocationStatic {
+			continue
+		}
+
+		statics = append(statics, StaticSegment{
+			Object:    info.object.Name,
+			Segment:   info.segment.Name,
+			Address:   i.start,
+			Size:      i.end - i.start,
+			Alignment: max(info.segment.Alignment, 1),
+			Source:    source[info.id],
+			Symbols:   staticSymbols(info, i.start, offsets[info.id], s.symbols),
+		})
+	}
+
+	return statics, issues
+}
+
+// Variables of a segment, exports named and referenced offsets anonymous
+func staticSymbols(info *segmentInfo, address uint32, referenced []uint32, symbols *symbolTable) []StaticSymbol {
+	named := map[uint32]string{}
+	for _, export := range info.exports {
+		named[export.offset] = symbols.names[export.symbol]
+	}
+
+	offsets := slices.Clone(referenced)
+	for offset := range named {
+		offsets = append(offsets, offset)
+	}
+	slices.Sort(offsets)
+	offsets = slices.Compact(offsets)
+
+	size := uint32(len(info.segment.Data))
+	var result []StaticSymbol
+	for i, offset := range offsets {
+		end := size
+		if i + 1 < len(offsets) {
+			end = offsets[i + 1]
+		}
+
+		result = append(result, StaticSymbol{
+			Name:    named[offset],
+			Address: address + offset,
+			Size:    end - offset,
+		})
+	}
+
+	return result
+}
+
+// Code segment an object is ordered by
+func (s *solver) firstCode(object *omf.Object) *segmentInfo {
+	var first *segmentInfo
+	for _, segment := range object.Segments[omf.LocationText] {
+		info := s.symbols.infos[segment]
+		address, placed := s.st.value(info.id)
+		if !placed || len(segment.Data) == 0 {
+			continue
+		}
+		if first == nil || cmp.Less(address, s.st.values[first.id]) {
+			first = info
+		}
+	}
+
+	return first
+}
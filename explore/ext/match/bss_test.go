@@ -0,0 +1,122 @@
+package match
+
+import (
+	"math/rand/v2"
+	"strings"
+	"testing"
+
+	"github.com/dexter3k/watre/explore/ext/omf"
+)
+
+// Object with a unique code segment and a BSS segment of the given size,
+// which the code refers to at offset 16 unless referenced is false
+func staticObject(r *rand.Rand, name string, size uint32, referenced bool) *omf.Object {
+	object, text := codeObject(r, name, "lib.lib", 48, "_" + strings.TrimSuffix(name, ".c"))
+	bss := addSegment(object, omf.LocationStatic, "_BSS", make([]byte, size), 4)
+	bss.Exports["_" + strings.TrimSuffix(name, ".c") + "_var"] = 0
+	if referenced {
+		clear(text.Data[16:][:4])
+		text.Relocs[16] = &omf.LocalRelocation{
+			Type:     omf.RelocationAbsolute32,
+			LocalRef: omf.SegmentRef{Location: omf.LocationStatic, Name: "_BSS", Offset: 8},
+		}
+	}
+	return object
+}
+
+func staticOf(t *testing.T, result *Result, object string) StaticSegment {
+	t.Helper()
+	for _, static := range result.Statics {
+		if static.Object == object {
+			return static
+		}
+	}
+	t.Fatalf("BSS of %s is not placed", object)
+	return StaticSegment{}
+}
+
+func TestStaticsFromRelocationsAndLinkOrder(t *testing.T) {
+	r := rand.New(rand.NewPCG(13, 14))
+	first := staticObject(r, "first.c", 0x10, true)
+	second := staticObject(r, "second.c", 0x0c, false)
+	third := staticObject(r, "third.c", 0x08, false)
+
+	l := link(0x401000, 0x410000, first, second, third)
+	result := Match(l.target(), []*omf.Object{first, second, third})
+
+	tests := []struct {
+		object *omf.Object
+		source StaticSource
+	}{
+		{first, StaticSourceRelocation},
+		{second, StaticSourceLinkOrder},
+		{third, StaticSourceLinkOrder},
+	}
+	for _, test := range tests {
+		static := staticOf(t, result, test.object.Name)
+		expected := l.segments[test.object.Segments[omf.LocationStatic][0]]
+		if static.Address != expected || static.Source != test.source {
+			t.Errorf("BSS of %s at %08x from %q, expected %08x from %q", test.object.Name, static.Address, static.Source, expected, test.source)
+		}
+	}
+
+	// The referenced variable and the export of the segment are both known
+	symbols := staticOf(t, result, "first.c").Symbols
+	if len(symbols) != 2 || symbols[0].Name != "_first_var" || symbols[0].Size != 8 || symbols[1].Name != "" || symbols[1].Address != 0x410008 {
+		t.Errorf("Variables of first.c are %+v", symbols)
+	}
+	if len(result.StaticIssues) != 0 {
+		t.Errorf("Issues: %q", result.StaticIssues)
+	}
+}
+
+func TestStaticsNotPredictedAcrossUnmatchedCode(t *testing.T) {
+	r := rand.New(rand.NewPCG(15, 16))
+	first := staticObject(r, "first.c", 0x10, true)
+	unmatched := staticObject(r, "unmatched.c", 0x20, false)
+	last := staticObject(r, "last.c", 0x08, false)
+
+	l := link(0x401000, 0x410000, first, unmatched, last)
+	result := Match(l.target(), []*omf.Object{first, last})
+
+	if len(result.PlacementsOf("last.c")) == 0 {
+		t.Fatalf("Code of last.c is not placed")
+	}
+	for _, static := range result.Statics {
+		if static.Object == "last.c" {
+			t.Errorf("BSS of last.c predicted at %08x, linked at %08x", static.Address, l.segments[last.Segments[omf.LocationStatic][0]])
+		}
+	}
+}
+
+func TestStaticIssues(t *testing.T) {
+	tests := []struct {
+		name    string
+		offset  uint32
+		bssBase uint32
+		issue   string
+	}{
+		{"reference past the end", 0x20, 0x410000, "past the end"},
+		{"misaligned segment", 0x08, 0x410002, "not aligned"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := rand.New(rand.NewPCG(17, 18))
+			object := staticObject(r, "broken.c", 0x10, true)
+			reloc := object.Segments[omf.LocationText][0].Relocs[16].(*omf.LocalRelocation)
+			reloc.LocalRef.Offset = test.offset
+
+			l := link(0x401000, test.bssBase, object)
+			result := Match(l.target(), []*omf.Object{object})
+
+			found := false
+			for _, issue := range result.StaticIssues {
+				found = found || strings.Contains(issue, test.issue)
+			}
+			if !found {
+				t.Errorf("Issues are %q, expected one containing %q", result.StaticIssues, test.issue)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package match
+
+import (
+	"bytes"
+	"iter"
+	"slices"
+
+	"github.com/dexter3k/watre/explore/ext/omf"
+)
+
+// Segments with no relocation-free run this long are searched for byte by byte
+const indexWindowSize = 8
+
+// Offsets of every window of the image, sorted by the hash of the window.
+// Each entry holds the hash in the upper half and the offset in the lower one.
+type windowIndex struct {
+	entries []uint64
+}
+
+// FNV-1a
+func windowHash(window []byte) uint32 {
+	hash := uint32(2166136261)
+	for _, b := range window {
+		hash ^= uint32(b)
+		hash *= 16777619
+	}
+	return hash
+}
+
+func newWindowIndex(data []byte) *windowIndex {
+	if len(data) < indexWindowSize {
+		return &windowIndex{}
+	}
+
+	entries := make([]uint64, len(data) - indexWindowSize + 1)
+	for i := range entries {
+		entries[i] = uint64(windowHash(data[i:][:indexWindowSize])) << 32 | uint64(i)
+	}
+	slices.Sort(entries)
+
+	return &windowIndex{
+		entries: entries,
+	}
+}
+
+// Entries of windows with the given hash, ordered by offset
+func (x *windowIndex) lookup(hash uint32) []uint64 {
+	start, _ := slices.BinarySearch(x.entries, uint64(hash) << 32)
+	end := len(x.entries)
+	if hash != 0xffffffff {
+		end, _ = slices.BinarySearch(x.entries, uint64(hash + 1) << 32)
+	}
+	return x.entries[start:end]
+}
+
+// Builds one index per region, locations sharing a region share the index
+func buildIndexes(target *Target) map[omf.Location]*windowIndex {
+	indexes := map[omf.Location]*windowIndex{}
+	for location := omf.Location(0); location < omf.LocationCount; location++ {
+		region := target.Regions[location]
+		if location == omf.LocationStatic || len(region.Data) == 0 {
+			continue
+		}
+
+		for other, index := range indexes {
+			if target.Regions[other].Base == region.Base && len(target.Regions[other].Data) == len(region.Data) {
+				indexes[location] = index
+				break
+			}
+		}
+		if indexes[location] == nil {
+			indexes[location] = newWindowIndex(region.Data)
+		}
+	}
+
+	return indexes
+}
+
+// Start and length of the longest run of bytes not covered by relocations
+func relocationFreeRun(segment *omf.Segment) (int, int) {
+	bestStart, bestLength := 0, 0
+	start := 0
+	for i := 0; i <= len(segment.Data); i++ {
+		reloc, found := segment.Relocs[uint32(i)]
+		if !found && i < len(segment.Data) {
+			continue
+		}
+
+		if i - start > bestLength {
+			bestStart, bestLength = start, i - start
+		}
+		if found {
+			i += reloc.GetType().Size() - 1
+		}
+		start = i + 1
+	}
+
+	return bestStart, bestLength
+}
+
+// Offsets within the location the segment could start at, in increasing order.
+// Only the rarest window of the longest relocation-free run is looked up.
+func (m *matchingContext) candidateOffsets(location omf.Location, segment *omf.Segment) iter.Seq[int] {
+	data := m.locationMap[location]
+	limit := len(data) - len(segment.Data)
+	index := m.indexes[location]
+
+	runStart, runLength := relocationFreeRun(segment)
+	if index == nil || runLength < indexWindowSize {
+		// Fall back to scanning for the first byte of the run
+		return func(yield func(int) bool) {
+			for i := 0; i <= limit; i++ {
+				if runLength != 0 {
+					j := bytes.IndexByte(data[i + runStart:], segment.Data[runStart])
+					if j == -1 {
+						return
+					}
+					i += j
+				}
+				if i > limit {
+					return
+				}
+
+				if !yield(i) {
+					return
+				}
+			}
+		}
+	}
+
+	var rarest []uint64
+	rarestOffset := -1
+	for offset := runStart; offset + indexWindowSize <= runStart + runLength; offset++ {
+		entries := index.lookup(windowHash(segment.Data[offset:][:indexWindowSize]))
+		if rarestOffset == -1 || len(entries) < len(rarest) {
+			rarest, rarestOffset = entries, offset
+		}
+		if len(rarest) == 0 {
+			break
+		}
+	}
+	window := segment.Data[rarestOffset:][:indexWindowSize]
+
+	return func(yield func(int) bool) {
+		for _, entry := range rarest {
+			i := int(uint32(entry)) - rarestOffset
+			if i < 0 || i > limit {
+				continue
+			}
+			// Hashes may collide
+			if !bytes.Equal(data[i + rarestOffset:][:indexWindowSize], window) {
+				continue
+			}
+
+			if !yield(i) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package match
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dexter3k/watre/explore/ext/exe"
+	"github.com/dexter3k/watre/explore/ext/omf"
+)
+
+// Image base and the last address of the sections of the image.
+// Headers are mapped at the image base, relocations may point there too.
+func imageBounds(img *exe.Image) (uint32, uint32) {
+	if img == nil || len(img.Sections) == 0 {
+		return 0, 0xffffffff
+	}
+
+	low, end := img.Base, img.Sections[0].End()
+	for _, section := range img.Sections {
+		low = min(low, section.Address)
+		end = max(end, section.End())
+	}
+
+	return low, end - 1
+}
+
+// Searches segments of the location in the given range of the image.
+// Statics are not read, their region is zero-filled.
+func (t *Target) SetRegion(location omf.Location, base, size uint32) error {
+	if location == omf.LocationStatic {
+		t.Regions[location] = Region{Base: base, Data: make([]byte, size)}
+		return nil
+	}
+	if t.Image == nil {
+		return fmt.Errorf("No image to read %s from", location)
+	}
+
+	data, err := t.Image.Bytes(base, size)
+	if err != nil {
+		return fmt.Errorf("%s region: %w", location, err)
+	}
+
+	t.Regions[location] = Region{Base: base, Data: data}
+	return nil
+}
+
+func parseHex(s string) (uint32, error) {
+	value, err := strconv.ParseUint(strings.TrimPrefix(s, "0x"), 16, 32)
+	return uint32(value), err
+}
+
+// Overrides the layout derived from the image, for unusual layouts.
+// Takes "bounds=LOW-HIGH", "selector=SEL" or "LOCATION=BASE+SIZE", in hex,
+// with LOCATION being CODE, DATA (or BEGDATA), CONST or BSS. Constants share
+// their region with data, so DATA moves CONST along unless it has its own.
+func (t *Target) Override(spec string) error {
+	key, value, found := strings.Cut(spec, "=")
+	if !found {
+		return fmt.Errorf("Invalid layout override: %q", spec)
+	}
+
+	if key == "selector" {
+		selector, err := parseHex(value)
+		if err != nil || selector > 0xffff {
+			return fmt.Errorf("Invalid flat selector: %q", value)
+		}
+
+		t.FlatSelector = uint16(selector)
+		return nil
+	}
+
+	if key == "bounds" {
+		lowText, highText, found := strings.Cut(value, "-")
+		if !found {
+			return fmt.Errorf("Invalid bounds: %q", value)
+		}
+		low, err := parseHex(lowText)
+		if err != nil {
+			return fmt.Errorf("Invalid low bound: %w", err)
+		}
+		high, err := parseHex(highText)
+		if err != nil {
+			return fmt.Errorf("Invalid high bound: %w", err)
+		}
+		if low > high {
+			return fmt.Errorf("Empty bounds: %08x-%08x", low, high)
+		}
+
+		t.LowAddress, t.HighAddress = low, high
+		return nil
+	}
+
+	location, err := omf.LocationFromName(key)
+	if err != nil {
+		return err
+	}
+	if location == omf.LocationStack {
+		return fmt.Errorf("Stack segments are not matched, no region to override")
+	}
+	baseText, sizeText, found := strings.Cut(value, "+")
+	if !found {
+		return fmt.Errorf("Invalid %s region: %q", location, value)
+	}
+	base, err := parseHex(baseText)
+	if err != nil {
+		return fmt.Errorf("Invalid %s base: %w", location, err)
+	}
+	size, err := parseHex(sizeText)
+	if err != nil {
+		return fmt.Errorf("Invalid %s size: %w", location, err)
+	}
+
+	previous := t.Regions[location]
+	if err := t.SetRegion(location, base, size); err != nil {
+		return err
+	}
+
+	if constants := t.Regions[omf.LocationConst]; location == omf.LocationData && constants.Base == previous.Base && len(constants.Data) == len(previous.Data) {
+		t.Regions[omf.LocationConst] = t.Regions[location]
+	}
+	return nil
+}
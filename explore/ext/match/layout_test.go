@@ -0,0 +1,115 @@
+package match
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dexter3k/watre/explore/ext/exe"
+	"github.com/dexter3k/watre/explore/ext/omf"
+)
+
+// Image with code at 00401000 and data at 00402000, as wlink lays it out
+func testImage() *exe.Image {
+	return &exe.Image{
+		Base: 0x400000,
+		Sections: []exe.ImageSection{
+			{Name: "AUTO", Address: 0x401000, Size: 0x1000, Data: make([]byte, 0x1000)},
+			{Name: "DGROUP", Address: 0x402000, Size: 0x1000, Data: make([]byte, 0x800)},
+		},
+	}
+}
+
+func TestImageBounds(t *testing.T) {
+	if low, high := imageBounds(testImage()); low != 0x400000 || high != 0x402fff {
+		t.Errorf("Bounds are %08x-%08x", low, high)
+	}
+	if low, high := imageBounds(nil); low != 0 || high != 0xffffffff {
+		t.Errorf("Bounds without an image are %08x-%08x", low, high)
+	}
+}
+
+func TestOverrideData(t *testing.T) {
+	shared := func() *Target {
+		data := Region{Base: 0x402000, Data: make([]byte, 0x1000)}
+		return &Target{
+			Image:   testImage(),
+			Regions: map[omf.Location]Region{omf.LocationData: data, omf.LocationConst: data},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		specs     []string
+		data      uint32
+		constants uint32
+	}{
+		{"data moves constants along", []string{"DATA=402100+100"}, 0x402100, 0x402100},
+		{"BEGDATA is data", []string{"BEGDATA=402100+100"}, 0x402100, 0x402100},
+		{"constants first", []string{"CONST=402800+100", "DATA=402100+100"}, 0x402100, 0x402800},
+		{"constants last", []string{"DATA=402100+100", "CONST=402800+100"}, 0x402100, 0x402800},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			target := shared()
+			for _, spec := range test.specs {
+				if err := target.Override(spec); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			data, constants := target.Regions[omf.LocationData], target.Regions[omf.LocationConst]
+			if data.Base != test.data || constants.Base != test.constants || len(data.Data) != 0x100 || len(constants.Data) != 0x100 {
+				t.Errorf("DATA at %08x+%x, CONST at %08x+%x", data.Base, len(data.Data), constants.Base, len(constants.Data))
+			}
+		})
+	}
+}
+
+func TestOverride(t *testing.T) {
+	target := &Target{Regions: map[omf.Location]Region{}}
+	for _, spec := range []string{"bounds=400000-4fffff", "selector=0x23", "BSS=480000+100"} {
+		if err := target.Override(spec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if target.LowAddress != 0x400000 || target.HighAddress != 0x4fffff {
+		t.Errorf("Bounds are %08x-%08x", target.LowAddress, target.HighAddress)
+	}
+	if target.FlatSelector != 0x23 {
+		t.Errorf("Flat selector is %04x", target.FlatSelector)
+	}
+	if region := target.Regions[omf.LocationStatic]; region.Base != 0x480000 || len(region.Data) != 0x100 {
+		t.Errorf("BSS region at %08x+%x", region.Base, len(region.Data))
+	}
+}
+
+func TestOverrideMalformed(t *testing.T) {
+	tests := []struct {
+		spec   string
+		errors string
+	}{
+		{"bounds", "Invalid layout override"},
+		{"bounds=400000", "Invalid bounds"},
+		{"bounds=4fffff-400000", "Empty bounds"},
+		{"bounds=zz-400000", "Invalid low bound"},
+		{"selector=10000", "Invalid flat selector"},
+		{"selector=cs", "Invalid flat selector"},
+		{"TEXT=401000+100", "Unknown location"},
+		{"BSS=480000", "Invalid BSS region"},
+		{"BSS=480000+zz", "Invalid BSS size"},
+		{"CODE=401000+100", "No image"},
+		{"STACK=490000+1000", "Stack segments"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.spec, func(t *testing.T) {
+			target := &Target{Regions: map[omf.Location]Region{}}
+			err := target.Override(test.spec)
+			if err == nil || !strings.Contains(err.Error(), test.errors) {
+				t.Fatalf("Expected an error containing %q, got %v", test.errors, err)
+			}
+		})
+	}
+}
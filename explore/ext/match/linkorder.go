@@ -0,0 +1,108 @@
+package match
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/dexter3k/watre/explore/ext/exe"
+	"github.com/dexter3k/watre/explore/ext/omf"
+)
+
+// wlink lays out objects one after another in the order it resolved them,
+// each segment padded to the alignment of the next one. Objects of the same
+// library end up next to each other, so a placed segment predicts where its
+// neighbours from that library start and end.
+
+// Whether the segment would sit right next to an already placed segment of
+// an object from the same library
+func (s *solver) adjacent(info *segmentInfo, address uint32) bool {
+	size := uint32(len(info.segment.Data))
+	if size == 0 {
+		return false
+	}
+
+	at, _ := slices.BinarySearchFunc(s.intervals, address, func(i interval, start uint32) int {
+		return cmp.Compare(i.start, start)
+	})
+
+	if at > 0 {
+		prev := s.intervals[at - 1]
+		if exe.AlignUp(prev.end, info.segment.Alignment) == address && s.sameLibrary(info, prev.symbol) {
+			return true
+		}
+	}
+	if at < len(s.intervals) {
+		next := s.intervals[at]
+		nextInfo := s.symbols.segments[next.symbol]
+		if exe.AlignUp(address + size, nextInfo.segment.Alignment) == next.start && s.sameLibrary(info, next.symbol) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *solver) sameLibrary(info *segmentInfo, id symbolID) bool {
+	other := s.symbols.segments[id]
+	return other != nil && other.object != info.object && other.object.Library == info.object.Library
+}
+
+// Whether any segment of the object itself would be next to a placed neighbour
+func (s *solver) followsLinkOrder(index int, candidate singleObjectValidMatch) bool {
+	for _, b := range candidate.bindings {
+		info := s.symbols.segments[b.symbol]
+		if !s.symbols.local[b.symbol] || info == nil || info.object != s.vars[index].source {
+			continue
+		}
+		if s.adjacent(info, b.address) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Narrows objects with several candidates, deferred ones included, down to
+// the only candidate next to a placed neighbour. Reports whether anything was placed.
+func (s *solver) followLinkOrder() bool {
+	changed := false
+	for index, v := range s.vars {
+		if v.placed || len(v.candidates) < 2 {
+			continue
+		}
+
+		var next []singleObjectValidMatch
+		for _, candidate := range v.candidates {
+			if s.followsLinkOrder(index, candidate) && s.fits(index, candidate) {
+				next = append(next, candidate)
+			}
+		}
+		if len(next) != 1 {
+			continue
+		}
+
+		v.candidates = next
+		v.deferred = false
+		v.confidence = ConfidenceLinkOrder
+		s.place(index)
+		changed = true
+	}
+
+	return changed
+}
+
+// Placed objects by the address of their first code segment
+func (s *solver) linkOrder() []string {
+	var order []string
+	seen := map[*omf.Object]struct{}{}
+	for _, i := range s.intervals {
+		info := s.symbols.segments[i.symbol]
+		if _, found := seen[info.object]; found || info.location != omf.LocationText {
+			continue
+		}
+		seen[info.object] = struct{}{}
+		order = append(order, info.object.Name)
+	}
+
+	return order
+}
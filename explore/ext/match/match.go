@@ -0,0 +1,256 @@
+// Package match places OMF objects into a linked image. Every segment is
+// searched for in the image, its relocations constrain where the segments
+// and globals it references can be, and only placements consistent across
+// all of an object's segments survive.
+package match
+
+import (
+	"runtime"
+	"sync"
+
+	"github.com/dexter3k/watre/explore/ext/exe"
+	"github.com/dexter3k/watre/explore/ext/omf"
+)
+
+// Part of the image segments of one location are searched in
+type Region struct {
+	Base uint32
+	Data []byte
+}
+
+type Target struct {
+	Image   *exe.Image
+	Regions map[omf.Location]Region
+
+	// Relocations pointing outside are rejected, inclusive
+	LowAddress  uint32
+	HighAddress uint32
+
+	// Selector of 48-bit far pointers, the image does not tell it, see Override.
+	// When zero, it is learned from the matches and only needs to be the same for all of them.
+	FlatSelector uint16
+}
+
+// Code goes to the code section, data and constants to DGROUP, statics to BSS.
+// Relocations may point anywhere within the sections of the image.
+func NewTarget(watcom *exe.WatcomExe) *Target {
+	low, high := imageBounds(watcom.Image)
+	return &Target{
+		Image: watcom.Image,
+		Regions: map[omf.Location]Region{
+			omf.LocationText:   {Base: watcom.CodeBase, Data: watcom.Code},
+			omf.LocationData:   {Base: watcom.DataBase, Data: watcom.Data},
+			omf.LocationConst:  {Base: watcom.DataBase, Data: watcom.Data},
+			omf.LocationStatic: {Base: watcom.BssBase, Data: make([]byte, watcom.BssLength)},
+			omf.LocationStack:  {},
+		},
+
+		LowAddress:  low,
+		HighAddress: high,
+	}
+}
+
+// One consistent way to place an object. Locals are keyed by full segment
+// names as produced by SegmentName, globals by their public names.
+type Candidate struct {
+	Globals map[string]uint32
+	Locals  map[string]uint32
+}
+
+// How a placement was arrived at, from most to least certain
+const (
+	// The object matched at a single place
+	ConfidenceUnique = "unique"
+	// Other candidates of the object contradict placements of other objects
+	ConfidenceConstrained = "constrained"
+	// The only candidate next to a placed object of the same library
+	ConfidenceLinkOrder = "link order"
+	// The object was not placed, other objects refer to the segment
+	ConfidenceReference = "reference"
+)
+
+type Placement struct {
+	Object   string
+	Library  string
+	Location omf.Location
+	Segment  string
+	Address  uint32
+	Size     uint32
+
+	Alignment  uint32
+	Confidence string
+}
+
+// A resolved global, or a static name of a placed segment
+type Symbol struct {
+	Name    string
+	Address uint32
+	// Object exporting the symbol, or the one whose relocations point at it
+	Object  string
+	Library string
+	// "export" when the exporting segment is placed, "reference" otherwise,
+	// "local" for static names
+	Source string
+}
+
+type Ambiguity struct {
+	Object     string
+	Library    string
+	Candidates []Candidate
+}
+
+// An object none of whose candidates fit with the placed objects
+type Conflict struct {
+	Object  string
+	Library string
+	// Why each candidate was rejected
+	Reasons []string
+}
+
+type Result struct {
+	Placements []Placement
+	Globals    map[string]uint32
+	Locals     map[string]uint32
+	// Globals and then static names, each by name, along with where their
+	// addresses come from
+	Symbols []Symbol
+
+	// Objects left with several candidates consistent with everything else
+	Ambiguous []Ambiguity
+	// Objects whose candidates all contradict placements of other objects
+	Conflicting []Conflict
+
+	// Objects with placed code, in the order the linker laid them out
+	LinkOrder []string
+
+	// Placed BSS segments by address, with the variables within them
+	Statics []StaticSegment
+	// Where relocations, sizes, alignment and link order of BSS segments disagree
+	StaticIssues []string
+
+	// Selector used by far pointers of placed objects, zero if they have none
+	FlatSelector uint16
+}
+
+// Full name of a segment, used as a key of Candidate.Locals
+func SegmentName(object string, location omf.Location, segment string) string {
+	return fullSegmentName(object, location, segment)
+}
+
+func (r *Result) PlacementsOf(object string) []Placement {
+	var result []Placement
+	for _, placement := range r.Placements {
+		if placement.Object == object {
+			result = append(result, placement)
+		}
+	}
+
+	return result
+}
+
+// Splits bindings back into named globals and locals
+func (t *symbolTable) candidate(bindings []binding) Candidate {
+	candidate := Candidate{
+		Globals: map[string]uint32{},
+		Locals:  map[string]uint32{},
+	}
+	for _, b := range bindings {
+		if b.symbol == t.flatSelector {
+			continue
+		}
+		if t.local[b.symbol] {
+			candidate.Locals[t.names[b.symbol]] = b.address
+		} else {
+			candidate.Globals[t.names[b.symbol]] = b.address
+		}
+	}
+
+	return candidate
+}
+
+// Matches every object individually, then solves for placements of all
+// objects that agree on symbol addresses and do not overlap.
+// Uses one worker per available CPU.
+func Match(target *Target, objects []*omf.Object) *Result {
+	return MatchWithWorkers(target, objects, runtime.GOMAXPROCS(0))
+}
+
+// Same as Match, with objects searched for by the given number of workers.
+// The result does not depend on the number of workers.
+func MatchWithWorkers(target *Target, objects []*omf.Object, workers int) *Result {
+	con := &matchingContext{
+		objects: objects,
+
+		locationMap:  map[omf.Location][]byte{},
+		locationBase: map[omf.Location]uint32{},
+		indexes:      buildIndexes(target),
+
+		lowAddress:   target.LowAddress,
+		highAddress:  target.HighAddress,
+		flatSelector: target.FlatSelector,
+	}
+	for location, region := range target.Regions {
+		con.locationMap[location] = region.Data
+		con.locationBase[location] = region.Base
+	}
+	con.symbols = newSymbolTable(objects, con.locationMap)
+
+	// Objects are independent from each other, each worker picks the next one
+	// and keeps its own store
+	matchesPerObject := make([][]singleObjectValidMatch, len(objects))
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	for range max(workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			st := newStore(len(con.symbols.names))
+			for index := range indices {
+				matchesPerObject[index] = con.matchEntireObject(st, objects[index])
+			}
+		}()
+	}
+	for index := range objects {
+		indices <- index
+	}
+	close(indices)
+	wg.Wait()
+
+	solver := newSolver(con.symbols)
+	solver.solve(objects, matchesPerObject)
+
+	result := &Result{
+		LinkOrder: solver.linkOrder(),
+	}
+	result.Statics, result.StaticIssues = solver.placeStatics()
+	if selector, found := solver.st.value(con.symbols.flatSelector); found {
+		result.FlatSelector = uint16(selector)
+	}
+	for _, v := range solver.vars {
+		switch {
+		case len(v.candidates) == 0:
+			result.Conflicting = append(result.Conflicting, Conflict{
+				Object:  v.object,
+				Library: v.source.Library,
+				Reasons: v.reasons,
+			})
+		case !v.placed:
+			ambiguity := Ambiguity{
+				Object:  v.object,
+				Library: v.source.Library,
+			}
+			for _, match := range v.candidates {
+				ambiguity.Candidates = append(ambiguity.Candidates, con.symbols.candidate(match.bindings))
+			}
+			result.Ambiguous = append(result.Ambiguous, ambiguity)
+		}
+	}
+
+	merged := con.symbols.candidate(solver.st.bindingsSince(0))
+	result.Globals = merged.Globals
+	result.Locals = merged.Locals
+	result.Placements = solver.placements(result.Statics)
+	result.Symbols = solver.resolvedSymbols()
+
+	return result
+}
@@ -0,0 +1,206 @@
+package match
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand/v2"
+	"runtime"
+	"testing"
+
+	"github.com/dexter3k/watre/explore/ext/exe"
+	"github.com/dexter3k/watre/explore/ext/omf"
+)
+
+// Selector the test linker writes into 48-bit pointers
+const testFlatSelector = 0x17
+
+func addSegment(object *omf.Object, location omf.Location, name string, data []byte, alignment uint32) *omf.Segment {
+	segment := &omf.Segment{
+		Name:      name,
+		Data:      data,
+		Relocs:    map[uint32]omf.Relocation{},
+		Exports:   map[string]uint32{},
+		Alignment: alignment,
+	}
+	object.Segments[location] = append(object.Segments[location], segment)
+	return segment
+}
+
+// Image laid out the way wlink does it: code segments of the objects one
+// after another, each aligned and padded with int3, then their BSS segments
+// in the same order
+type testLink struct {
+	codeBase uint32
+	code     []byte
+	bssBase  uint32
+	bssSize  uint32
+
+	segments map[*omf.Segment]uint32
+	globals  map[string]uint32
+}
+
+func link(codeBase, bssBase uint32, objects ...*omf.Object) *testLink {
+	l := &testLink{
+		codeBase: codeBase,
+		bssBase:  bssBase,
+		segments: map[*omf.Segment]uint32{},
+		globals:  map[string]uint32{},
+	}
+
+	for _, object := range objects {
+		for _, segment := range object.Segments[omf.LocationText] {
+			offset := exe.AlignUp(uint32(len(l.code)), segment.Alignment)
+			for uint32(len(l.code)) < offset {
+				l.code = append(l.code, 0xcc)
+			}
+			l.segments[segment] = codeBase + offset
+			l.code = append(l.code, segment.Data...)
+		}
+	}
+	for _, object := range objects {
+		for _, segment := range object.Segments[omf.LocationStatic] {
+			l.bssSize = exe.AlignUp(l.bssSize, segment.Alignment)
+			l.segments[segment] = bssBase + l.bssSize
+			l.bssSize += uint32(len(segment.Data))
+		}
+	}
+
+	for segment, address := range l.segments {
+		for name, offset := range segment.Exports {
+			l.globals[name] = address + offset
+		}
+	}
+
+	for _, object := range objects {
+		for _, segment := range object.Segments[omf.LocationText] {
+			for offset, reloc := range segment.Relocs {
+				l.resolve(object, segment, offset, reloc)
+			}
+		}
+	}
+
+	return l
+}
+
+func (l *testLink) resolve(object *omf.Object, segment *omf.Segment, offset uint32, reloc omf.Relocation) {
+	var target uint32
+	switch reloc := reloc.(type) {
+	case *omf.GlobalRelocation:
+		address, found := l.globals[reloc.GlobalName]
+		if !found {
+			panic(fmt.Errorf("Unresolved global: %s", reloc.GlobalName))
+		}
+		target = address + reloc.Offset
+	case *omf.LocalRelocation:
+		referenced := object.GetSegment(reloc.LocalRef.Location, reloc.LocalRef.Name)
+		target = l.segments[referenced] + reloc.LocalRef.Offset
+	}
+
+	address := l.segments[segment] + offset
+	at := l.code[address - l.codeBase:]
+	switch reloc.GetType() {
+	case omf.RelocationAbsolute32:
+		binary.LittleEndian.PutUint32(at, target)
+	case omf.RelocationAbsolute48:
+		binary.LittleEndian.PutUint32(at, target)
+		binary.LittleEndian.PutUint16(at[4:], testFlatSelector)
+	case omf.RelocationRelative32:
+		binary.LittleEndian.PutUint32(at, target - address - 4)
+	}
+}
+
+func (l *testLink) target() *Target {
+	return &Target{
+		Regions: map[omf.Location]Region{
+			omf.LocationText:   {Base: l.codeBase, Data: l.code},
+			omf.LocationStatic: {Base: l.bssBase, Data: make([]byte, l.bssSize)},
+		},
+
+		LowAddress:  l.codeBase,
+		HighAddress: l.bssBase + max(l.bssSize, 1) - 1,
+	}
+}
+
+// Library of count objects in several libraries, calling earlier objects and
+// referring to their own code and statics. Only the first linked objects make
+// up the image, the rest are searched for in vain.
+func generateObjects(count, linked int) ([]*omf.Object, *testLink) {
+	r := rand.New(rand.NewPCG(1, 2))
+
+	var objects []*omf.Object
+	for i := range count {
+		object := &omf.Object{
+			Name:    fmt.Sprintf("obj%03d.c", i),
+			Library: fmt.Sprintf("lib%d.lib", i / 50),
+		}
+
+		data := make([]byte, 48 + r.IntN(208))
+		for j := range data {
+			data[j] = byte(r.IntN(256))
+		}
+		text := addSegment(object, omf.LocationText, "_TEXT", data, 4)
+		text.Exports[fmt.Sprintf("_f%d", i)] = 0
+		if len(data) > 64 {
+			text.Exports[fmt.Sprintf("_g%d", i)] = uint32(len(data) / 2)
+		}
+
+		// Calls to earlier objects, every 8 bytes
+		for k := range r.IntN(4) {
+			if i == 0 {
+				break
+			}
+			offset := uint32(1 + 8 * k)
+			data[offset - 1] = 0xe8
+			clear(data[offset:][:4])
+			text.Relocs[offset] = &omf.GlobalRelocation{
+				Type:       omf.RelocationRelative32,
+				GlobalName: fmt.Sprintf("_f%d", r.IntN(i)),
+			}
+		}
+
+		// A jump table at the end refers back to the start of the segment
+		end := uint32(len(data) - 4)
+		clear(data[end:])
+		text.Relocs[end] = &omf.LocalRelocation{
+			Type:     omf.RelocationAbsolute32,
+			LocalRef: omf.SegmentRef{Location: omf.LocationText, Name: "_TEXT"},
+		}
+
+		if i % 3 == 0 {
+			addSegment(object, omf.LocationStatic, "_BSS", make([]byte, 4 + r.IntN(60)), 4)
+			// Only some are referenced, the rest are placed by link order
+			if i % 2 == 0 {
+				offset := end - 8
+				clear(data[offset:][:4])
+				text.Relocs[offset] = &omf.LocalRelocation{
+					Type:     omf.RelocationAbsolute32,
+					LocalRef: omf.SegmentRef{Location: omf.LocationStatic, Name: "_BSS"},
+				}
+			}
+		}
+
+		objects = append(objects, object)
+	}
+
+	return objects, link(0x401000, 0x480000, objects[:linked]...)
+}
+
+// Matches a generated 400 object library against an image of 300 of them,
+// on a single worker and on all of them
+func BenchmarkMatch(b *testing.B) {
+	objects, l := generateObjects(400, 300)
+	target := l.target()
+
+	workerCounts := []int{1}
+	if runtime.GOMAXPROCS(0) > 1 {
+		workerCounts = append(workerCounts, runtime.GOMAXPROCS(0))
+	}
+	for _, workers := range workerCounts {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				MatchWithWorkers(target, objects, workers)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package match
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"github.com/dexter3k/watre/explore/ext/omf"
+)
+
+// Every placed segment by address, BSS included
+func (s *solver) placements(statics []StaticSegment) []Placement {
+	byLinkOrder := map[string]struct{}{}
+	for _, static := range statics {
+		if static.Source == StaticSourceLinkOrder {
+			byLinkOrder[SegmentName(static.Object, omf.LocationStatic, static.Segment)] = struct{}{}
+		}
+	}
+
+	varOf := map[*omf.Object]*variable{}
+	for _, v := range s.vars {
+		varOf[v.source] = v
+	}
+
+	var result []Placement
+	for _, b := range s.st.bindingsSince(0) {
+		info := s.symbols.segments[b.symbol]
+		if !s.symbols.local[b.symbol] || info == nil {
+			continue
+		}
+
+		confidence := ConfidenceReference
+		if v := varOf[info.object]; v != nil && v.placed {
+			confidence = v.confidence
+		}
+		if _, found := byLinkOrder[s.symbols.names[b.symbol]]; found {
+			confidence = ConfidenceLinkOrder
+		}
+
+		result = append(result, Placement{
+			Object:     info.object.Name,
+			Library:    info.object.Library,
+			Location:   info.location,
+			Segment:    info.segment.Name,
+			Address:    b.address,
+			Size:       uint32(len(info.segment.Data)),
+			Alignment:  max(info.segment.Alignment, 1),
+			Confidence: confidence,
+		})
+	}
+
+	slices.SortFunc(result, func(a, b Placement) int {
+		if a.Address != b.Address {
+			return cmp.Compare(a.Address, b.Address)
+		}
+		return strings.Compare(SegmentName(a.Object, a.Location, a.Segment), SegmentName(b.Object, b.Location, b.Segment))
+	})
+
+	return result
+}
+
+// Assigned globals by name, attributed to their exporter when it is placed
+func (s *solver) resolvedSymbols() []Symbol {
+	var result []Symbol
+	for _, b := range s.st.bindingsSince(0) {
+		if s.symbols.local[b.symbol] || b.symbol == s.symbols.flatSelector {
+			continue
+		}
+
+		symbol := Symbol{
+			Name:    s.symbols.names[b.symbol],
+			Address: b.address,
+			Source:  "reference",
+		}
+		object := s.vars[s.owner[b.symbol]].source
+		if export := s.symbols.exporters[b.symbol]; export.symbol != noSymbol {
+			if _, placed := s.st.value(export.symbol); placed {
+				object = s.symbols.segments[export.symbol].object
+				symbol.Source = "export"
+			}
+		}
+		symbol.Object = object.Name
+		symbol.Library = object.Library
+
+		result = append(result, symbol)
+	}
+
+	slices.SortFunc(result, func(a, b Symbol) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return append(result, s.localSymbols()...)
+}
+
+// Static names within placed segments, by name and address
+func (s *solver) localSymbols() []Symbol {
+	var result []Symbol
+	for _, b := range s.st.bindingsSince(0) {
+		info := s.symbols.segments[b.symbol]
+		if !s.symbols.local[b.symbol] || info == nil {
+			continue
+		}
+
+		for name, offset := range info.segment.LocalExports {
+			result = append(result, Symbol{
+				Name:    name,
+				Address: b.address + offset,
+				Object:  info.object.Name,
+				Library: info.object.Library,
+				Source:  "local",
+			})
+		}
+	}
+
+	slices.SortFunc(result, func(a, b Symbol) int {
+		if a.Name != b.Name {
+			return strings.Compare(a.Name, b.Name)
+		}
+		return cmp.Compare(a.Address, b.Address)
+	})
+
+	return result
+}
@@ -0,0 +1,257 @@
+package match
+
+import (
+	"encoding/binary"
+	"fmt"
+	"bytes"
+	"slices"
+
+	"github.com/dexter3k/watre/explore/ext/omf"
+)
+
+func fullSegmentName(object string, location omf.Location, segment string) string {
+	return fmt.Sprintf("%q:%s:%q", object, location, segment)
+}
+
+type matchingContext struct {
+	objects []*omf.Object
+	symbols *symbolTable
+
+	locationMap  map[omf.Location][]byte
+	locationBase map[omf.Location]uint32
+	// Read-only once matching starts
+	indexes map[omf.Location]*windowIndex
+
+	lowAddress  uint32
+	highAddress uint32
+	// Zero when not known, far pointers then only need to agree with each other
+	flatSelector uint16
+}
+
+// Bindings of all globals and segments one placement of an object implies, sorted by symbol
+type singleObjectValidMatch struct {
+	bindings []binding
+}
+
+// Places the segment at base, binding the segment itself, its exports and
+// everything its relocations point to. Assignments are left in the store
+// even on failure, the caller rolls them back.
+func (m *matchingContext) trySegmentMatch(st *store, info *segmentInfo, data []byte, base uint32) bool {
+	if !st.assign(info.id, base) {
+		return false
+	}
+
+	if !m.tryMatchingSegmentTo(st, info, data, base) {
+		return false
+	}
+
+	for _, export := range info.exports {
+		if !st.assign(export.symbol, base + export.offset) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Checks everything assigned since the given point of the trail: segments
+// must match at their addresses and globals must be where their segments are.
+// The trail grows while it is walked, so dependencies of dependencies are checked too.
+func (m *matchingContext) propagate(st *store, from int, matched symbolID) bool {
+	for k := from; k < len(st.trail); k++ {
+		id := st.trail[k]
+		address := st.values[id]
+		if id == matched {
+			continue
+		}
+
+		if !m.symbols.local[id] {
+			export := m.symbols.exporters[id]
+			if export.symbol == noSymbol {
+				continue
+			}
+
+			info := m.symbols.segments[export.symbol]
+			segAddress := address - export.offset
+			if segAddress < m.locationBase[info.location] || segAddress - m.locationBase[info.location] >= uint32(len(m.locationMap[info.location])) {
+				return false
+			}
+
+			// The segment itself is checked when the walk gets to it
+			if !st.assign(info.id, segAddress) {
+				return false
+			}
+			continue
+		}
+
+		info := m.symbols.segments[id]
+		if info == nil {
+			panic(fmt.Errorf("Missing dependent segment: %s", m.symbols.names[id]))
+		}
+
+		base := m.locationBase[info.location]
+		// Check the address is within bounds of the section
+		if address < base || address > base + uint32(len(m.locationMap[info.location])) {
+			return false
+		}
+
+		if !m.trySegmentMatch(st, info, m.locationMap[info.location][address - base:], address) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (m *matchingContext) getSegmentMatches(st *store, info *segmentInfo) ([]singleObjectValidMatch, bool) {
+	var matches []singleObjectValidMatch
+
+	if len(info.segment.Data) == 0 {
+		return matches, true
+	}
+
+	data := m.locationMap[info.location]
+	base := m.locationBase[info.location]
+
+	for i := range m.candidateOffsets(info.location, info.segment) {
+		checkpoint := st.checkpoint()
+
+		if m.trySegmentMatch(st, info, data[i:], base + uint32(i)) && m.propagate(st, checkpoint, info.id) {
+			matches = append(matches, singleObjectValidMatch{
+				bindings: st.bindingsSince(checkpoint),
+			})
+		}
+
+		st.rollback(checkpoint)
+	}
+
+	return matches, false
+}
+
+// Sorts the matches and drops exact duplicates
+func makeMatchesUnique(matches []singleObjectValidMatch) []singleObjectValidMatch {
+	slices.SortFunc(matches, func(a, b singleObjectValidMatch) int {
+		return compareBindings(a.bindings, b.bindings)
+	})
+
+	return slices.CompactFunc(matches, func(a, b singleObjectValidMatch) bool {
+		return compareBindings(a.bindings, b.bindings) == 0
+	})
+}
+
+func (m *matchingContext) matchEntireObject(st *store, object *omf.Object) []singleObjectValidMatch {
+	matchesOnThisObject := []singleObjectValidMatch{}
+
+	for location := omf.Location(0); location < omf.LocationCount; location++ {
+		if _, found := m.locationMap[location]; !found || location == omf.LocationStatic {
+			continue
+		}
+		for _, segment := range object.Segments[location] {
+			matchesOnThisSegment, skip := m.getSegmentMatches(st, m.symbols.infos[segment])
+			if skip {
+				continue
+			}
+
+			if len(matchesOnThisSegment) == 0 {
+				return nil
+			}
+
+			if len(matchesOnThisObject) == 0 {
+				matchesOnThisObject = matchesOnThisSegment
+			} else {
+				// Explode matches with previous finds
+				// so if we've found A, B, C
+				// and in this segment D, E
+				// Leave AD, BD, CD, AE, BE, CE
+				combinedMatches := []singleObjectValidMatch{}
+				for _, matchThisSegment := range matchesOnThisSegment {
+					for _, matchPreviously := range matchesOnThisObject {
+						if bindingsConflict(matchThisSegment.bindings, matchPreviously.bindings) {
+							continue
+						}
+
+						combinedMatches = append(combinedMatches, singleObjectValidMatch{
+							bindings: mergeBindings(matchThisSegment.bindings, matchPreviously.bindings),
+						})
+					}
+				}
+
+				if len(combinedMatches) == 0 {
+					return nil
+				}
+
+				matchesOnThisObject = combinedMatches
+			}
+
+			// And collapse exactly equal matches, to leave just the unique ones
+			matchesOnThisObject = makeMatchesUnique(matchesOnThisObject)
+		}
+	}
+
+	return matchesOnThisObject
+}
+
+func (m *matchingContext) tryMatchingSegmentTo(st *store, info *segmentInfo, section []byte, sectionBase uint32) bool {
+	segment := info.segment.Data
+	if len(segment) == 0 {
+		return true
+	}
+
+	if len(section) < len(segment) {
+		return false
+	}
+
+	i := 0
+	for _, rel := range info.relocs {
+		// Covered by the previous relocation
+		if int(rel.offset) < i {
+			continue
+		}
+
+		// Malformed objects may have relocations past their data
+		if int(rel.offset) + rel.kind.Size() > len(segment) {
+			return false
+		}
+
+		// Bytes up to the relocation must be equal
+		if !bytes.Equal(segment[i:rel.offset], section[i:rel.offset]) {
+			return false
+		}
+		i = int(rel.offset)
+
+		target := binary.LittleEndian.Uint32(section[i:][:4])
+		target -= rel.addend
+
+		switch rel.kind {
+		case omf.RelocationAbsolute32:
+			// no additional adjustments
+		case omf.RelocationAbsolute48:
+			// 32-bit offset followed by the selector, which is the same flat one everywhere
+			selector := binary.LittleEndian.Uint16(section[i + 4:][:2])
+			if m.flatSelector != 0 && selector != m.flatSelector {
+				return false
+			}
+			if !st.assign(m.symbols.flatSelector, uint32(selector)) {
+				return false
+			}
+		case omf.RelocationRelative32:
+			target += sectionBase
+			target += uint32(i)
+			target += 4
+		default:
+			panic(fmt.Errorf("Unknown relocation kind: %s", rel.kind))
+		}
+
+		if target < m.lowAddress || target > m.highAddress {
+			return false
+		}
+
+		if !st.assign(rel.symbol, target) {
+			return false
+		}
+
+		i += rel.kind.Size()
+	}
+
+	return bytes.Equal(segment[i:], section[i:len(segment)])
+}
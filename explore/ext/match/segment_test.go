@@ -0,0 +1,35 @@
+package match
+
+import (
+	"testing"
+
+	"github.com/dexter3k/watre/explore/ext/omf"
+)
+
+func TestRelocationsPastSegmentData(t *testing.T) {
+	tests := []struct {
+		name   string
+		offset uint32
+		kind   omf.RelocationType
+	}{
+		{"relocation starting past the end", 0x20, omf.RelocationAbsolute32},
+		{"relocation crossing the end", 0x0e, omf.RelocationAbsolute32},
+		{"far pointer crossing the end", 0x0c, omf.RelocationAbsolute48},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			object := &omf.Object{Name: "broken.c"}
+			data := []byte{0x55, 0x89, 0xe5, 0x53, 0x56, 0x57, 0x8b, 0x45, 0x08, 0x5f, 0x5e, 0x5b, 0x5d, 0xc3, 0x90, 0x90}
+			text := addSegment(object, omf.LocationText, "_TEXT", data, 1)
+			text.Relocs[test.offset] = &omf.GlobalRelocation{Type: test.kind, GlobalName: "_missing"}
+
+			// The bytes are all there, only the relocation does not fit
+			l := &testLink{codeBase: 0x401000, code: append(append([]byte{}, data...), make([]byte, 0x40)...), bssBase: 0x402000}
+			result := Match(l.target(), []*omf.Object{object})
+			if len(result.Placements) != 0 {
+				t.Errorf("Placed at %08x", result.Placements[0].Address)
+			}
+		})
+	}
+}
@@ -0,0 +1,433 @@
+package match
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/dexter3k/watre/explore/ext/omf"
+)
+
+// Search steps allowed when checking whether a candidate is part of any solution.
+// Candidates the search gives up on are kept.
+const solverNodeBudget = 1000
+
+// Candidate checks allowed across all searches of a solve. Once spent,
+// pruning stops and what is left open goes to the link order.
+const solverWorkBudget = 2000000
+
+// Address range taken by a placed segment
+type interval struct {
+	start  uint32
+	end    uint32
+	symbol symbolID
+}
+
+// Objects with more candidates are too small to be placed on their own
+// and are only placed next to their neighbours in the link order
+const maxCandidates = 16
+
+// An object and the candidates it may still be placed at
+type variable struct {
+	object     string
+	candidates []singleObjectValidMatch
+
+	source   *omf.Object
+	placed   bool
+	deferred bool
+	// How the candidate was chosen, once placed
+	confidence string
+	// Reasons each candidate was rejected for, once none are left
+	reasons []string
+}
+
+// Places objects so that symbol addresses agree and segments do not overlap.
+// Objects are variables, their candidates are the domains. Forced placements
+// are propagated first, then the rest is searched with backtracking.
+type solver struct {
+	symbols *symbolTable
+	st      *store
+
+	vars []*variable
+	// Variable that assigned each symbol first, valid while the symbol is assigned
+	owner     []int
+	intervals []interval
+
+	nodes int
+	work  int
+}
+
+func newSolver(symbols *symbolTable) *solver {
+	return &solver{
+		symbols: symbols,
+		st:      newStore(len(symbols.names)),
+		owner:   make([]int, len(symbols.names)),
+	}
+}
+
+func (s *solver) size(id symbolID) uint32 {
+	if !s.symbols.local[id] || s.symbols.segments[id] == nil {
+		return 0
+	}
+	return uint32(len(s.symbols.segments[id].segment.Data))
+}
+
+func (s *solver) describe(id symbolID) string {
+	return fmt.Sprintf("%s at %08x", s.symbols.names[id], s.st.values[id])
+}
+
+// Returns the interval overlapping the given range, if any
+func (s *solver) overlapping(start, end uint32) (interval, bool) {
+	at, _ := slices.BinarySearchFunc(s.intervals, start, func(i interval, start uint32) int {
+		return cmp.Compare(i.start, start)
+	})
+	if at > 0 && s.intervals[at - 1].end > start {
+		return s.intervals[at - 1], true
+	}
+	if at < len(s.intervals) && s.intervals[at].start < end {
+		return s.intervals[at], true
+	}
+	return interval{}, false
+}
+
+func (s *solver) removeInterval(id symbolID) {
+	at := slices.IndexFunc(s.intervals, func(i interval) bool {
+		return i.symbol == id
+	})
+	if at != -1 {
+		s.intervals = slices.Delete(s.intervals, at, at + 1)
+	}
+}
+
+func (s *solver) rollback(checkpoint int) {
+	for _, id := range s.st.trail[checkpoint:] {
+		if s.size(id) != 0 {
+			s.removeInterval(id)
+		}
+	}
+	s.st.rollback(checkpoint)
+}
+
+// Applies a candidate of a variable. On failure nothing is applied and
+// the reason names the symbol or segment the candidate disagrees on.
+func (s *solver) try(index int, candidate singleObjectValidMatch) (bool, string) {
+	checkpoint := s.st.checkpoint()
+	for _, b := range candidate.bindings {
+		before := s.st.checkpoint()
+		if !s.st.assign(b.symbol, b.address) {
+			reason := fmt.Sprintf("%s at %08x, but %s placed it at %08x", s.symbols.names[b.symbol], b.address, s.vars[s.owner[b.symbol]].object, s.st.values[b.symbol])
+			s.rollback(checkpoint)
+			return false, reason
+		}
+		if s.st.checkpoint() == before {
+			continue
+		}
+
+		s.owner[b.symbol] = index
+		if size := s.size(b.symbol); size != 0 {
+			if other, found := s.overlapping(b.address, b.address + size); found {
+				reason := fmt.Sprintf("%s at %08x+%x overlaps %s+%x placed by %s", s.symbols.names[b.symbol], b.address, size, s.describe(other.symbol), other.end - other.start, s.vars[s.owner[other.symbol]].object)
+				s.rollback(checkpoint)
+				return false, reason
+			}
+
+			at, _ := slices.BinarySearchFunc(s.intervals, b.address, func(i interval, start uint32) int {
+				return cmp.Compare(i.start, start)
+			})
+			s.intervals = slices.Insert(s.intervals, at, interval{
+				start:  b.address,
+				end:    b.address + size,
+				symbol: b.symbol,
+			})
+		}
+	}
+
+	return true, ""
+}
+
+// Whether the candidate is consistent with everything placed so far
+func (s *solver) fits(index int, candidate singleObjectValidMatch) bool {
+	checkpoint := s.st.checkpoint()
+	ok, _ := s.try(index, candidate)
+	s.rollback(checkpoint)
+	return ok
+}
+
+func (s *solver) place(index int) {
+	if ok, reason := s.try(index, s.vars[index].candidates[0]); !ok {
+		panic(fmt.Errorf("%s: Placing the last candidate failed: %s", s.vars[index].object, reason))
+	}
+	s.vars[index].placed = true
+	if s.vars[index].confidence == "" {
+		s.vars[index].confidence = ConfidenceConstrained
+	}
+}
+
+func (s *solver) open() []int {
+	var open []int
+	for index, v := range s.vars {
+		if !v.placed && !v.deferred && len(v.candidates) > 0 {
+			open = append(open, index)
+		}
+	}
+	return open
+}
+
+// Drops candidates inconsistent with what is placed and places objects left
+// with a single candidate, until nothing changes. Objects left with none
+// are conflicts, the reasons of each candidate are kept.
+func (s *solver) propagate() {
+	for changed := true; changed; {
+		changed = false
+		for _, index := range s.open() {
+			v := s.vars[index]
+
+			var kept []singleObjectValidMatch
+			var reasons []string
+			for _, candidate := range v.candidates {
+				checkpoint := s.st.checkpoint()
+				if ok, reason := s.try(index, candidate); ok {
+					kept = append(kept, candidate)
+				} else {
+					reasons = append(reasons, reason)
+				}
+				s.rollback(checkpoint)
+			}
+
+			v.candidates = kept
+			switch len(kept) {
+			case 0:
+				v.reasons = reasons
+			case 1:
+				s.place(index)
+				changed = true
+			}
+		}
+	}
+}
+
+// Looks for a placement of every open object, with the candidate of the
+// first one fixed. Reports true when the budget runs out.
+func (s *solver) search(open []int) bool {
+	if len(open) == 0 {
+		return true
+	}
+	s.nodes++
+	if s.nodes > solverNodeBudget || s.work > solverWorkBudget {
+		return true
+	}
+
+	// Continue with the object with the fewest fitting candidates
+	best, bestCount := -1, 0
+	for i, index := range open {
+		count := 0
+		for _, candidate := range s.vars[index].candidates {
+			s.work++
+			if s.fits(index, candidate) {
+				count++
+			}
+		}
+		if count == 0 {
+			return false
+		}
+		if best == -1 || count < bestCount {
+			best, bestCount = i, count
+		}
+	}
+
+	index := open[best]
+	rest := slices.Delete(slices.Clone(open), best, best + 1)
+	for _, candidate := range s.vars[index].candidates {
+		checkpoint := s.st.checkpoint()
+		if ok, _ := s.try(index, candidate); ok && s.search(rest) {
+			s.rollback(checkpoint)
+			return true
+		}
+		s.rollback(checkpoint)
+	}
+
+	return false
+}
+
+// Segment a candidate of an open object places
+type candidateRange struct {
+	interval
+	index int
+}
+
+// Open objects by the symbols and ranges their candidates bind, so that
+// searches only involve objects that can affect each other
+type neighbourhood struct {
+	bySymbol map[symbolID][]int
+	// Sorted by start
+	ranges  []candidateRange
+	longest uint32
+}
+
+func (s *solver) neighbourhood(open []int) *neighbourhood {
+	n := &neighbourhood{bySymbol: map[symbolID][]int{}}
+	for _, index := range open {
+		for _, candidate := range s.vars[index].candidates {
+			for _, b := range candidate.bindings {
+				if b.symbol == s.symbols.flatSelector {
+					continue
+				}
+				if users := n.bySymbol[b.symbol]; len(users) == 0 || users[len(users) - 1] != index {
+					n.bySymbol[b.symbol] = append(users, index)
+				}
+				if size := s.size(b.symbol); size != 0 {
+					n.ranges = append(n.ranges, candidateRange{interval{b.address, b.address + size, b.symbol}, index})
+					n.longest = max(n.longest, size)
+				}
+			}
+		}
+	}
+	slices.SortFunc(n.ranges, func(a, b candidateRange) int {
+		return cmp.Compare(a.start, b.start)
+	})
+
+	return n
+}
+
+// Other open objects sharing a symbol with the candidate, or with
+// a candidate overlapping one of its segments, in the order of open
+func (s *solver) related(n *neighbourhood, open []int, index int, candidate singleObjectValidMatch) []int {
+	found := map[int]struct{}{}
+	for _, b := range candidate.bindings {
+		if b.symbol == s.symbols.flatSelector {
+			continue
+		}
+		for _, other := range n.bySymbol[b.symbol] {
+			found[other] = struct{}{}
+		}
+
+		size := s.size(b.symbol)
+		if size == 0 {
+			continue
+		}
+		start, end := b.address, b.address + size
+		// Ranges starting more than the longest one before cannot reach start
+		from, _ := slices.BinarySearchFunc(n.ranges, start - min(start, n.longest), func(r candidateRange, start uint32) int {
+			return cmp.Compare(r.start, start)
+		})
+		for _, r := range n.ranges[from:] {
+			if r.start >= end {
+				break
+			}
+			if r.end > start {
+				found[r.index] = struct{}{}
+			}
+		}
+	}
+
+	var result []int
+	for _, other := range open {
+		if _, ok := found[other]; ok && other != index {
+			result = append(result, other)
+		}
+	}
+	return result
+}
+
+// Drops candidates that are not part of any placement of the objects around
+// them. Does nothing for an object when there is no placement of it and its
+// neighbours at all, as then some of them are not in the image and nothing
+// can be concluded. Work is bounded across the whole solve.
+func (s *solver) prune() bool {
+	open := s.open()
+	if len(open) == 0 || s.work > solverWorkBudget {
+		return false
+	}
+	n := s.neighbourhood(open)
+
+	changed := false
+	for _, index := range open {
+		v := s.vars[index]
+
+		related := make([][]int, len(v.candidates))
+		group := []int{index}
+		for i, candidate := range v.candidates {
+			related[i] = s.related(n, open, index, candidate)
+			for _, other := range related[i] {
+				if !slices.Contains(group, other) {
+					group = append(group, other)
+				}
+			}
+		}
+		s.nodes = 0
+		if !s.search(group) {
+			continue
+		}
+
+		var kept []singleObjectValidMatch
+		for i, candidate := range v.candidates {
+			checkpoint := s.st.checkpoint()
+			s.nodes = 0
+			if ok, _ := s.try(index, candidate); ok && s.search(related[i]) {
+				kept = append(kept, candidate)
+			}
+			s.rollback(checkpoint)
+		}
+
+		if len(kept) < len(v.candidates) {
+			v.candidates = kept
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+// Objects with a single candidate are placed first, largest first, as
+// larger matches are less likely to be coincidental. Those that
+// contradict a larger one become conflicts. Whatever propagation and
+// search leave open is then narrowed down by the link order.
+func (s *solver) solve(objects []*omf.Object, matches [][]singleObjectValidMatch) {
+	for i, object := range objects {
+		if len(matches[i]) == 0 {
+			continue
+		}
+		s.vars = append(s.vars, &variable{
+			object:     object.Name,
+			candidates: matches[i],
+			source:     object,
+			deferred:   len(matches[i]) > maxCandidates,
+		})
+	}
+
+	unique := []int{}
+	for index, v := range s.vars {
+		if len(v.candidates) == 1 {
+			unique = append(unique, index)
+		}
+	}
+	slices.SortStableFunc(unique, func(a, b int) int {
+		if lhs, rhs := len(s.vars[a].candidates[0].bindings), len(s.vars[b].candidates[0].bindings); lhs != rhs {
+			return rhs - lhs
+		}
+		return strings.Compare(s.vars[a].object, s.vars[b].object)
+	})
+	for _, index := range unique {
+		v := s.vars[index]
+		checkpoint := s.st.checkpoint()
+		if ok, reason := s.try(index, v.candidates[0]); ok {
+			v.placed = true
+			v.confidence = ConfidenceUnique
+		} else {
+			s.rollback(checkpoint)
+			v.candidates = nil
+			v.reasons = []string{reason}
+		}
+	}
+
+	for {
+		s.propagate()
+		if s.prune() {
+			continue
+		}
+		if !s.followLinkOrder() {
+			break
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package match
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/dexter3k/watre/explore/ext/omf"
+)
+
+// Object with a single code segment of random bytes exporting the given global
+func codeObject(r *rand.Rand, name, library string, size int, export string) (*omf.Object, *omf.Segment) {
+	object := &omf.Object{Name: name, Library: library}
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(r.IntN(256))
+	}
+	segment := addSegment(object, omf.LocationText, "_TEXT", data, 4)
+	if export != "" {
+		segment.Exports[export] = 0
+	}
+	return object, segment
+}
+
+// Object with the same bytes under another name, for duplicates in the image
+func copyObject(object *omf.Object, name, export string) *omf.Object {
+	copied := &omf.Object{Name: name, Library: "other.lib"}
+	segment := addSegment(copied, omf.LocationText, "_TEXT", object.Segments[omf.LocationText][0].Data, 4)
+	segment.Exports[export] = 0
+	return copied
+}
+
+func callTo(segment *omf.Segment, offset uint32, global string) {
+	segment.Data[offset - 1] = 0xe8
+	clear(segment.Data[offset:][:4])
+	segment.Relocs[offset] = &omf.GlobalRelocation{Type: omf.RelocationRelative32, GlobalName: global}
+}
+
+func placementOf(t *testing.T, result *Result, object *omf.Object) Placement {
+	t.Helper()
+	placements := result.PlacementsOf(object.Name)
+	if len(placements) != 1 {
+		t.Fatalf("%s has %d placements", object.Name, len(placements))
+	}
+	return placements[0]
+}
+
+func TestMatchGenerated(t *testing.T) {
+	objects, l := generateObjects(400, 300)
+	result := Match(l.target(), objects)
+
+	for i, object := range objects {
+		placements := result.PlacementsOf(object.Name)
+		if i >= 300 {
+			if len(placements) != 0 {
+				t.Errorf("%s is not linked, but placed at %08x", object.Name, placements[0].Address)
+			}
+			continue
+		}
+
+		if len(placements) == 0 {
+			t.Errorf("%s is not placed", object.Name)
+		}
+		for _, placement := range placements {
+			segment := object.GetSegment(placement.Location, placement.Segment)
+			if expected := l.segments[segment]; placement.Address != expected {
+				t.Errorf("%s:%s placed at %08x, linked at %08x", object.Name, placement.Segment, placement.Address, expected)
+			}
+		}
+	}
+	if len(result.Ambiguous) != 0 || len(result.Conflicting) != 0 || len(result.StaticIssues) != 0 {
+		t.Errorf("%d ambiguous, %d conflicting, issues: %q", len(result.Ambiguous), len(result.Conflicting), result.StaticIssues)
+	}
+}
+
+func TestCandidatesConstrainedByCallers(t *testing.T) {
+	r := rand.New(rand.NewPCG(3, 4))
+	stub, _ := codeObject(r, "stub.c", "a.lib", 12, "_stub")
+	caller, text := codeObject(r, "caller.c", "b.lib", 40, "_caller")
+	callTo(text, 9, "_stub")
+	decoy := copyObject(stub, "decoy.c", "_decoy")
+
+	l := link(0x401000, 0x410000, decoy, caller, stub)
+	result := Match(l.target(), []*omf.Object{stub, caller})
+
+	if placement := placementOf(t, result, caller); placement.Confidence != ConfidenceUnique {
+		t.Errorf("Caller placed with confidence %q", placement.Confidence)
+	}
+	placement := placementOf(t, result, stub)
+	if placement.Address != l.globals["_stub"] || placement.Confidence != ConfidenceConstrained {
+		t.Errorf("Stub placed at %08x (%s), linked at %08x", placement.Address, placement.Confidence, l.globals["_stub"])
+	}
+}
+
+func TestAmbiguousDuplicates(t *testing.T) {
+	r := rand.New(rand.NewPCG(5, 6))
+	stub, _ := codeObject(r, "stub.c", "a.lib", 12, "_stub")
+	decoy := copyObject(stub, "decoy.c", "_decoy")
+
+	l := link(0x401000, 0x410000, decoy, stub)
+	result := Match(l.target(), []*omf.Object{stub})
+
+	if len(result.Ambiguous) != 1 || len(result.Ambiguous[0].Candidates) != 2 {
+		t.Fatalf("Ambiguities are %+v", result.Ambiguous)
+	}
+	if len(result.PlacementsOf(stub.Name)) != 0 {
+		t.Errorf("Ambiguous object is placed")
+	}
+}
+
+func TestLinkOrderPicksNeighbour(t *testing.T) {
+	r := rand.New(rand.NewPCG(7, 8))
+	first, _ := codeObject(r, "first.c", "lib.lib", 64, "_first")
+	stub, _ := codeObject(r, "stub.c", "lib.lib", 12, "_stub")
+	filler, _ := codeObject(r, "filler.c", "other.lib", 32, "_filler")
+	decoy := copyObject(stub, "decoy.c", "_decoy")
+
+	l := link(0x401000, 0x410000, first, stub, filler, decoy)
+	result := Match(l.target(), []*omf.Object{first, stub})
+
+	placement := placementOf(t, result, stub)
+	if placement.Address != l.globals["_stub"] || placement.Confidence != ConfidenceLinkOrder {
+		t.Errorf("Stub placed at %08x (%s), linked at %08x", placement.Address, placement.Confidence, l.globals["_stub"])
+	}
+	if len(result.LinkOrder) != 2 || result.LinkOrder[0] != "first.c" || result.LinkOrder[1] != "stub.c" {
+		t.Errorf("Link order is %q", result.LinkOrder)
+	}
+}
+
+func TestOverlappingObjectsConflict(t *testing.T) {
+	r := rand.New(rand.NewPCG(9, 10))
+	larger, text := codeObject(r, "larger.c", "a.lib", 64, "_larger")
+	// Same bytes as the start of the larger one
+	smaller := &omf.Object{Name: "smaller.c", Library: "a.lib"}
+	addSegment(smaller, omf.LocationText, "_TEXT", text.Data[:32], 4).Exports["_smaller"] = 0
+
+	l := link(0x401000, 0x410000, larger)
+	result := Match(l.target(), []*omf.Object{smaller, larger})
+
+	if placement := placementOf(t, result, larger); placement.Address != 0x401000 {
+		t.Errorf("Larger object placed at %08x", placement.Address)
+	}
+	if len(result.Conflicting) != 1 || result.Conflicting[0].Object != "smaller.c" {
+		t.Fatalf("Conflicts are %+v", result.Conflicting)
+	}
+	if reasons := result.Conflicting[0].Reasons; len(reasons) != 1 || !strings.Contains(reasons[0], "overlaps") {
+		t.Errorf("Reasons are %q", reasons)
+	}
+}
+
+func TestFlatSelector(t *testing.T) {
+	r := rand.New(rand.NewPCG(11, 12))
+	object, text := codeObject(r, "far.c", "a.lib", 40, "_far")
+	clear(text.Data[8:][:6])
+	text.Relocs[8] = &omf.GlobalRelocation{Type: omf.RelocationAbsolute48, GlobalName: "_far"}
+
+	l := link(0x401000, 0x410000, object)
+	if selector := binary.LittleEndian.Uint16(l.code[12:]); selector != testFlatSelector {
+		t.Fatalf("Linked selector is %04x", selector)
+	}
+
+	tests := []struct {
+		name     string
+		selector uint16
+		placed   bool
+	}{
+		{"learned", 0, true},
+		{"known", testFlatSelector, true},
+		{"different", 0x23, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			target := l.target()
+			target.FlatSelector = test.selector
+			result := Match(target, []*omf.Object{object})
+
+			if placed := len(result.PlacementsOf(object.Name)) != 0; placed != test.placed {
+				t.Fatalf("Placed is %v, expected %v", placed, test.placed)
+			}
+			if test.placed && result.FlatSelector != testFlatSelector {
+				t.Errorf("Flat selector is %04x", result.FlatSelector)
+			}
+		})
+	}
+}
+
+func TestLocalSymbols(t *testing.T) {
+	r := rand.New(rand.NewPCG(19, 20))
+	object, text := codeObject(r, "statics.c", "a.lib", 64, "_public")
+	text.LocalExports = map[string]uint32{"helper": 0x20, "table": 0x30}
+
+	l := link(0x401000, 0x410000, object)
+	result := Match(l.target(), []*omf.Object{object})
+
+	var locals []Symbol
+	for _, sym := range result.Symbols {
+		if sym.Source == "local" {
+			locals = append(locals, sym)
+		}
+	}
+	expected := []Symbol{
+		{Name: "helper", Address: 0x401020, Object: "statics.c", Library: "a.lib", Source: "local"},
+		{Name: "table", Address: 0x401030, Object: "statics.c", Library: "a.lib", Source: "local"},
+	}
+	if !slices.Equal(locals, expected) {
+		t.Errorf("Static names are %+v", locals)
+	}
+	if _, found := result.Globals["helper"]; found {
+		t.Errorf("Static name resolved as a global")
+	}
+}
+
+func TestPruneThroughCallers(t *testing.T) {
+	r := rand.New(rand.NewPCG(21, 22))
+	stub, _ := codeObject(r, "stub.c", "a.lib", 12, "_stub")
+	decoy := copyObject(stub, "decoy.c", "_decoy")
+	caller, text := codeObject(r, "caller.c", "b.lib", 40, "_caller")
+	callTo(text, 9, "_stub")
+	// Same code calling the same stub, so the caller itself stays ambiguous
+	callerCopy := &omf.Object{Name: "copy.c", Library: "other.lib"}
+	copied := addSegment(callerCopy, omf.LocationText, "_TEXT", slices.Clone(text.Data), 4)
+	copied.Relocs[9] = text.Relocs[9]
+
+	l := link(0x401000, 0x410000, decoy, caller, callerCopy, stub)
+	result := Match(l.target(), []*omf.Object{stub, caller})
+
+	placement := placementOf(t, result, stub)
+	if placement.Address != l.globals["_stub"] || placement.Confidence != ConfidenceConstrained {
+		t.Errorf("Stub placed at %08x (%s), linked at %08x", placement.Address, placement.Confidence, l.globals["_stub"])
+	}
+	if len(result.Ambiguous) != 1 || result.Ambiguous[0].Object != "caller.c" {
+		t.Errorf("Ambiguities are %+v", result.Ambiguous)
+	}
+}
+
+// Unrelated ambiguous objects are not searched together
+func TestPruneManyAmbiguous(t *testing.T) {
+	r := rand.New(rand.NewPCG(23, 24))
+	var objects, linked []*omf.Object
+	for i := range 300 {
+		stub, _ := codeObject(r, fmt.Sprintf("stub%03d.c", i), "a.lib", 24, fmt.Sprintf("_stub%d", i))
+		objects = append(objects, stub)
+		linked = append(linked, stub, copyObject(stub, fmt.Sprintf("decoy%03d.c", i), fmt.Sprintf("_decoy%d", i)))
+	}
+
+	l := link(0x401000, 0x480000, linked...)
+	result := Match(l.target(), objects)
+
+	if len(result.Ambiguous) != len(objects) || len(result.Placements) != 0 {
+		t.Errorf("%d ambiguous, %d placed", len(result.Ambiguous), len(result.Placements))
+	}
+}
@@ -0,0 +1,273 @@
+package match
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/dexter3k/watre/explore/ext/omf"
+)
+
+// Interned name of a global or of a segment (a local)
+type symbolID uint32
+
+type segmentInfo struct {
+	object   *omf.Object
+	location omf.Location
+	segment  *omf.Segment
+	id       symbolID
+
+	// Sorted by offset
+	relocs  []compiledReloc
+	exports []compiledExport
+}
+
+type compiledReloc struct {
+	offset uint32
+	kind   omf.RelocationType
+	addend uint32
+	symbol symbolID
+}
+
+type compiledExport struct {
+	symbol symbolID
+	offset uint32
+}
+
+// Names of all globals and segments of the matched objects. Built once
+// before matching starts and read-only afterwards.
+type symbolTable struct {
+	names []string
+	local []bool
+
+	globals map[string]symbolID
+	locals  map[string]symbolID
+
+	// Segment of each local, nil for globals and for segments missing from the objects
+	segments []*segmentInfo
+	// Segment defining each global, with the offset of the global within it
+	exporters []compiledExport
+
+	infos map[*omf.Segment]*segmentInfo
+
+	// Pseudo global holding the selector of 48-bit pointers
+	flatSelector symbolID
+}
+
+// Not a valid C or assembly identifier, so no real symbol clashes with it
+const flatSelectorName = "<flat selector>"
+
+func (t *symbolTable) intern(name string, local bool) symbolID {
+	ids := t.globals
+	if local {
+		ids = t.locals
+	}
+	if id, found := ids[name]; found {
+		return id
+	}
+
+	id := symbolID(len(t.names))
+	ids[name] = id
+	t.names = append(t.names, name)
+	t.local = append(t.local, local)
+	t.segments = append(t.segments, nil)
+	t.exporters = append(t.exporters, compiledExport{symbol: noSymbol})
+	return id
+}
+
+// Marks a missing exporter
+const noSymbol = ^symbolID(0)
+
+// Interns in object order, so IDs do not depend on map iteration.
+// Only segments in the given locations export their globals.
+func newSymbolTable(objects []*omf.Object, locations map[omf.Location][]byte) *symbolTable {
+	t := &symbolTable{
+		globals: map[string]symbolID{},
+		locals:  map[string]symbolID{},
+		infos:   map[*omf.Segment]*segmentInfo{},
+	}
+	t.flatSelector = t.intern(flatSelectorName, false)
+
+	var infos []*segmentInfo
+	for _, object := range objects {
+		for location := omf.Location(0); location < omf.LocationCount; location++ {
+			for _, segment := range object.Segments[location] {
+				info := &segmentInfo{
+					object:   object,
+					location: location,
+					segment:  segment,
+					id:       t.intern(fullSegmentName(object.Name, location, segment.Name), true),
+				}
+				if t.segments[info.id] == nil {
+					t.segments[info.id] = info
+				}
+				t.infos[segment] = info
+				infos = append(infos, info)
+			}
+		}
+	}
+
+	for _, info := range infos {
+		_, exported := locations[info.location]
+		for _, name := range slices.Sorted(maps.Keys(info.segment.Exports)) {
+			export := compiledExport{
+				symbol: t.intern(name, false),
+				offset: info.segment.Exports[name],
+			}
+			info.exports = append(info.exports, export)
+
+			if exported && t.exporters[export.symbol].symbol == noSymbol {
+				t.exporters[export.symbol] = compiledExport{
+					symbol: info.id,
+					offset: export.offset,
+				}
+			}
+		}
+
+		for _, offset := range slices.Sorted(maps.Keys(info.segment.Relocs)) {
+			reloc := info.segment.Relocs[offset]
+
+			var symbol symbolID
+			switch reloc := reloc.(type) {
+			case *omf.GlobalRelocation:
+				symbol = t.intern(reloc.GlobalName, false)
+			case *omf.LocalRelocation:
+				symbol = t.intern(fullSegmentName(info.object.Name, reloc.LocalRef.Location, reloc.LocalRef.Name), true)
+			default:
+				panic(fmt.Errorf("Unknown relocation type: %T", reloc))
+			}
+
+			info.relocs = append(info.relocs, compiledReloc{
+				offset: offset,
+				kind:   reloc.GetType(),
+				addend: reloc.GetOffset(),
+				symbol: symbol,
+			})
+		}
+	}
+
+	return t
+}
+
+type binding struct {
+	symbol  symbolID
+	address uint32
+}
+
+func compareBindings(a, b []binding) int {
+	return slices.CompareFunc(a, b, func(x, y binding) int {
+		if x.symbol != y.symbol {
+			return cmp.Compare(x.symbol, y.symbol)
+		}
+		return cmp.Compare(x.address, y.address)
+	})
+}
+
+// Whether two sets of bindings, both sorted by symbol, place a symbol differently
+func bindingsConflict(a, b []binding) bool {
+	for len(a) > 0 && len(b) > 0 {
+		switch {
+		case a[0].symbol < b[0].symbol:
+			a = a[1:]
+		case a[0].symbol > b[0].symbol:
+			b = b[1:]
+		default:
+			if a[0].address != b[0].address {
+				return true
+			}
+			a, b = a[1:], b[1:]
+		}
+	}
+
+	return false
+}
+
+// Union of two non-conflicting sets of bindings, both sorted by symbol
+func mergeBindings(a, b []binding) []binding {
+	result := make([]binding, 0, len(a) + len(b))
+	for len(a) > 0 && len(b) > 0 {
+		switch {
+		case a[0].symbol < b[0].symbol:
+			result, a = append(result, a[0]), a[1:]
+		case a[0].symbol > b[0].symbol:
+			result, b = append(result, b[0]), b[1:]
+		default:
+			result, a, b = append(result, a[0]), a[1:], b[1:]
+		}
+	}
+
+	result = append(result, a...)
+	return append(result, b...)
+}
+
+// Addresses assigned to symbols. Every assignment is recorded on the trail,
+// so everything assigned since a checkpoint can be undone at once.
+type store struct {
+	values   []uint32
+	assigned []bool
+	trail    []symbolID
+}
+
+func newStore(symbols int) *store {
+	return &store{
+		values:   make([]uint32, symbols),
+		assigned: make([]bool, symbols),
+	}
+}
+
+// Fails if the symbol is already assigned a different address
+func (s *store) assign(id symbolID, address uint32) bool {
+	if s.assigned[id] {
+		return s.values[id] == address
+	}
+
+	s.values[id] = address
+	s.assigned[id] = true
+	s.trail = append(s.trail, id)
+	return true
+}
+
+func (s *store) value(id symbolID) (uint32, bool) {
+	return s.values[id], s.assigned[id]
+}
+
+func (s *store) checkpoint() int {
+	return len(s.trail)
+}
+
+func (s *store) rollback(checkpoint int) {
+	for _, id := range s.trail[checkpoint:] {
+		s.assigned[id] = false
+	}
+	s.trail = s.trail[:checkpoint]
+}
+
+// Assigns all bindings or none of them
+func (s *store) assignAll(bindings []binding) bool {
+	checkpoint := s.checkpoint()
+	for _, b := range bindings {
+		if !s.assign(b.symbol, b.address) {
+			s.rollback(checkpoint)
+			return false
+		}
+	}
+
+	return true
+}
+
+// Assignments made since the checkpoint, sorted by symbol
+func (s *store) bindingsSince(checkpoint int) []binding {
+	result := make([]binding, 0, len(s.trail) - checkpoint)
+	for _, id := range s.trail[checkpoint:] {
+		result = append(result, binding{
+			symbol:  id,
+			address: s.values[id],
+		})
+	}
+	slices.SortFunc(result, func(a, b binding) int {
+		return cmp.Compare(a.symbol, b.symbol)
+	})
+
+	return result
+}
@@ -0,0 +1,39 @@
+// Package mmap maps whole files into memory, so that large executables and
+// libraries are paged in on demand instead of being read up front.
+package mmap
+
+import (
+	"os"
+)
+
+type File struct {
+	// Private copy-on-write mapping, writes never reach the file
+	Data []byte
+
+	unmap func() error
+}
+
+func Open(path string) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	return mapFile(f, info.Size())
+}
+
+func (m *File) Close() error {
+	if m.unmap == nil {
+		return nil
+	}
+
+	err := m.unmap()
+	m.Data, m.unmap = nil, nil
+	return err
+}
@@ -0,0 +1,18 @@
+//go:build !unix
+
+package mmap
+
+import (
+	"io"
+	"os"
+)
+
+// No mmap here, fall back to reading the whole file
+func mapFile(f *os.File, size int64) (*File, error) {
+	data := make([]byte, size)
+	if _, err := io.ReadFull(f, data); err != nil {
+		return nil, err
+	}
+
+	return &File{Data: data}, nil
+}
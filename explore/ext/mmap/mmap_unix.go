@@ -0,0 +1,30 @@
+//go:build unix
+
+package mmap
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+func mapFile(f *os.File, size int64) (*File, error) {
+	if size == 0 {
+		return &File{}, nil
+	}
+	if size != int64(int(size)) {
+		return nil, fmt.Errorf("%s is too large to map: %d", f.Name(), size)
+	}
+
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ | syscall.PROT_WRITE, syscall.MAP_PRIVATE)
+	if err != nil {
+		return nil, fmt.Errorf("mmap %s: %w", f.Name(), err)
+	}
+
+	return &File{
+		Data: data,
+		unmap: func() error {
+			return syscall.Munmap(data)
+		},
+	}, nil
+}
@@ -0,0 +1,113 @@
+package mz
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/dexter3k/watre/explore/ext/exe"
+)
+
+type Relocation struct {
+	Offset  uint16
+	Segment uint16
+}
+
+// Offset of the patched word within the load module
+func (r Relocation) Linear() uint32 {
+	return uint32(r.Segment) << 4 + uint32(r.Offset)
+}
+
+// Real-mode DOS executable
+type File struct {
+	Header      exe.DosHeader
+	Relocations []Relocation
+
+	// Program image, as copied into memory by DOS
+	LoadModule       []byte
+	LoadModuleOffset uint32
+
+	// Anything appended past the size declared in the header
+	Overlay       []byte
+	OverlayOffset uint32
+}
+
+func Read(r io.ReadSeeker) (*File, error) {
+	if _, err := r.Seek(0, 0); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse(data)
+}
+
+func Parse(data []byte) (*File, error) {
+	le := binary.LittleEndian
+
+	file := &File{}
+	if err := binary.Read(bytes.NewReader(data), le, &file.Header); err != nil {
+		return nil, err
+	}
+	h := &file.Header
+	if h.Magic != 0x5a4d && h.Magic != 0x4d5a {
+		return nil, fmt.Errorf("Invalid DOS Header Magic: %04x", h.Magic)
+	}
+
+	fileSize := h.FileSize()
+	if fileSize > uint32(len(data)) {
+		return nil, fmt.Errorf("DOS header declares %d bytes, but file has %d", fileSize, len(data))
+	}
+	if h.HeaderSize() > fileSize {
+		return nil, fmt.Errorf("DOS header size is larger than the file: %d", h.HeaderSize())
+	}
+
+	relocEnd := uint32(h.RelocationOffset) + uint32(h.RelocationCount) * 4
+	if relocEnd > uint32(len(data)) {
+		return nil, fmt.Errorf("DOS relocation table is out of bounds")
+	}
+	for i := uint32(0); i < uint32(h.RelocationCount); i++ {
+		entry := data[uint32(h.RelocationOffset) + i * 4:]
+		file.Relocations = append(file.Relocations, Relocation{
+			Offset:  le.Uint16(entry),
+			Segment: le.Uint16(entry[2:]),
+		})
+	}
+
+	file.LoadModuleOffset = h.HeaderSize()
+	file.LoadModule = data[h.HeaderSize():fileSize]
+
+	if fileSize < uint32(len(data)) {
+		file.OverlayOffset = fileSize
+		file.Overlay = data[fileSize:]
+	}
+
+	return file, nil
+}
+
+// Linear address of CS:IP, relative to the load segment
+func (f *File) EntryPoint() uint32 {
+	return uint32(f.Header.InitialCS) << 4 + uint32(f.Header.InitialIP)
+}
+
+// Maps the load module at linear address 0, as if loaded at segment zero.
+// The minimum extra allocation forms the zero-filled tail.
+func (f *File) Image() (*exe.Image, error) {
+	size := uint32(len(f.LoadModule)) + uint32(f.Header.MinAlloc) * 16
+
+	return &exe.Image{
+		Sections: []exe.ImageSection{
+			{
+				Name:            "LOAD",
+				Address:         0,
+				Size:            size,
+				Characteristics: exe.SectionCode | exe.SectionInitializedData | exe.SectionExecute | exe.SectionRead | exe.SectionWrite,
+				Offset:          f.LoadModuleOffset,
+				Data:            f.LoadModule,
+			},
+		},
+	}, nil
+}
@@ -0,0 +1,89 @@
+package mz
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/dexter3k/watre/explore/ext/exe"
+)
+
+// Builds an MZ with two relocations, a 16 byte load module and a 4 byte overlay
+func buildMZ(t *testing.T, mutate func(h *exe.DosHeader)) []byte {
+	h := exe.DosHeader{
+		Magic:            0x5a4d,
+		PageCount:        1,
+		LastPageBytes:    0x60,
+		HeaderParagraphs: 5,
+		RelocationCount:  2,
+		RelocationOffset: 0x40,
+		InitialCS:        1,
+		InitialIP:        2,
+	}
+	if mutate != nil {
+		mutate(&h)
+	}
+
+	var b bytes.Buffer
+	if err := binary.Write(&b, binary.LittleEndian, h); err != nil {
+		t.Fatal(err)
+	}
+	b.Truncate(0x40)
+	binary.Write(&b, binary.LittleEndian, [4]uint16{0x0003, 0x0000, 0x0001, 0x0001})
+	b.Write(make([]byte, 0x50 - b.Len()))
+	b.Write(bytes.Repeat([]byte{0x90}, 16))
+	b.Write([]byte("OVL!"))
+
+	return b.Bytes()
+}
+
+func TestParse(t *testing.T) {
+	file, err := Parse(buildMZ(t, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(file.Relocations) != 2 || file.Relocations[1].Linear() != 0x11 {
+		t.Errorf("Relocations are %v", file.Relocations)
+	}
+	if file.LoadModuleOffset != 0x50 || len(file.LoadModule) != 16 {
+		t.Errorf("Load module at %x, %d bytes long", file.LoadModuleOffset, len(file.LoadModule))
+	}
+	if file.OverlayOffset != 0x60 || string(file.Overlay) != "OVL!" {
+		t.Errorf("Overlay at %x is %q", file.OverlayOffset, file.Overlay)
+	}
+	if file.EntryPoint() != 0x12 {
+		t.Errorf("Entry point is %x", file.EntryPoint())
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(h *exe.DosHeader)
+		errors string
+	}{
+		{"bad magic", func(h *exe.DosHeader) {
+			h.Magic = 0x1234
+		}, "Magic"},
+		{"declared size past the end", func(h *exe.DosHeader) {
+			h.PageCount = 2
+		}, "declares"},
+		{"header larger than the file", func(h *exe.DosHeader) {
+			h.HeaderParagraphs = 0x100
+		}, "header size"},
+		{"relocations past the end", func(h *exe.DosHeader) {
+			h.RelocationCount = 0xffff
+		}, "out of bounds"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse(buildMZ(t, test.mutate))
+			if err == nil || !strings.Contains(err.Error(), test.errors) {
+				t.Fatalf("Expected an error containing %q, got %v", test.errors, err)
+			}
+		})
+	}
+}
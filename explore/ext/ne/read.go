@@ -0,0 +1,269 @@
+package ne
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/dexter3k/watre/explore/ext/exe"
+)
+
+var ErrNotNewExecutable = errors.New("not an NE executable")
+
+func Read(r io.ReadSeeker) (*File, error) {
+	if _, err := r.Seek(0, 0); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse(data)
+}
+
+func Parse(data []byte) (*File, error) {
+	le := binary.LittleEndian
+
+	file := &File{}
+	if err := binary.Read(bytes.NewReader(data), le, &file.Dos); err != nil {
+		return nil, err
+	}
+	if file.Dos.Magic != 0x5a4d {
+		return nil, fmt.Errorf("Invalid DOS Header Magic: %04x", file.Dos.Magic)
+	}
+
+	file.HeaderOffset = file.Dos.PeHeaderOffset
+	if uint64(file.HeaderOffset) + 0x40 > uint64(len(data)) || le.Uint16(data[file.HeaderOffset:]) != Signature {
+		return nil, ErrNotNewExecutable
+	}
+
+	header := data[file.HeaderOffset:]
+	if err := binary.Read(bytes.NewReader(header), le, &file.Header); err != nil {
+		return nil, err
+	}
+	h := &file.Header
+	if h.SectorShift() >= 32 {
+		return nil, fmt.Errorf("Invalid NE alignment shift: %d", h.AlignmentShift)
+	}
+
+	// Segment table
+	segmentTable := uint32(h.SegmentTableOffset)
+	if segmentTable + uint32(h.SegmentCount) * 8 > uint32(len(header)) {
+		return nil, fmt.Errorf("NE segment table is out of bounds")
+	}
+	for i := uint32(0); i < uint32(h.SegmentCount); i++ {
+		entry := header[segmentTable + i * 8:]
+
+		offset := uint64(le.Uint16(entry)) << h.SectorShift()
+		if offset > uint64(len(data)) {
+			return nil, fmt.Errorf("NE segment %d is out of file bounds", i + 1)
+		}
+
+		segment := Segment{
+			Offset:   uint32(offset),
+			Length:   uint32(le.Uint16(entry[2:])),
+			Flags:    SegmentFlags(le.Uint16(entry[4:])),
+			MinAlloc: uint32(le.Uint16(entry[6:])),
+		}
+		if segment.Length == 0 && segment.Offset != 0 {
+			segment.Length = 0x10000
+		}
+		if segment.MinAlloc == 0 {
+			segment.MinAlloc = 0x10000
+		}
+
+		if segment.Offset != 0 {
+			if uint64(segment.Offset) + uint64(segment.Length) > uint64(len(data)) {
+				return nil, fmt.Errorf("NE segment %d is out of file bounds", i + 1)
+			}
+			segment.Data = data[segment.Offset:][:segment.Length]
+
+			if segment.Flags.Has(SegmentRelocations) {
+				relocs, err := parseRelocations(data, segment.Offset + segment.Length)
+				if err != nil {
+					return nil, fmt.Errorf("NE segment %d: %w", i + 1, err)
+				}
+				segment.Relocations = relocs
+			}
+		}
+
+		file.Segments = append(file.Segments, segment)
+	}
+
+	if h.EntryTableOffset != 0 && uint32(h.EntryTableOffset) + uint32(h.EntryTableLength) <= uint32(len(header)) {
+		entries, err := parseEntries(header[h.EntryTableOffset:][:h.EntryTableLength])
+		if err != nil {
+			return nil, err
+		}
+		file.Entries = entries
+	}
+
+	if h.ResidentNamesOffset != 0 && uint32(h.ResidentNamesOffset) < uint32(len(header)) {
+		file.ResidentNames = parseNames(header[h.ResidentNamesOffset:])
+	}
+
+	if h.NonResidentNamesOffset != 0 && uint64(h.NonResidentNamesOffset) + uint64(h.NonResidentNamesSize) <= uint64(len(data)) {
+		file.NonResidentNames = parseNames(data[h.NonResidentNamesOffset:][:h.NonResidentNamesSize])
+	}
+
+	moduleTable := uint32(h.ModuleReferenceOffset)
+	importedNames := uint32(h.ImportedNamesOffset)
+	if moduleTable + uint32(h.ModuleReferenceCount) * 2 > uint32(len(header)) {
+		return nil, fmt.Errorf("NE module reference table is out of bounds")
+	}
+	for i := uint32(0); i < uint32(h.ModuleReferenceCount); i++ {
+		nameOffset := importedNames + uint32(le.Uint16(header[moduleTable + i * 2:]))
+		if nameOffset >= uint32(len(header)) || nameOffset + 1 + uint32(header[nameOffset]) > uint32(len(header)) {
+			return nil, fmt.Errorf("NE module reference %d is out of bounds", i + 1)
+		}
+		file.ModuleReferences = append(file.ModuleReferences, string(header[nameOffset + 1:][:header[nameOffset]]))
+	}
+
+	return file, nil
+}
+
+func parseRelocations(data []byte, offset uint32) ([]Relocation, error) {
+	le := binary.LittleEndian
+
+	if uint64(offset) + 2 > uint64(len(data)) {
+		return nil, fmt.Errorf("Relocation table is out of bounds")
+	}
+	count := uint32(le.Uint16(data[offset:]))
+	offset += 2
+	if uint64(offset) + uint64(count) * 8 > uint64(len(data)) {
+		return nil, fmt.Errorf("Relocation table is out of bounds")
+	}
+
+	var relocs []Relocation
+	for i := uint32(0); i < count; i++ {
+		entry := data[offset + i * 8:]
+
+		reloc := Relocation{
+			Source:   RelocationSource(entry[0]),
+			Target:   RelocationTarget(entry[1] & 3),
+			Additive: entry[1] & 4 != 0,
+			Offset:   le.Uint16(entry[2:]),
+		}
+
+		switch reloc.Target {
+		case RelocationTargetInternal:
+			reloc.Segment = entry[4]
+			reloc.TargetOffset = le.Uint16(entry[6:])
+		case RelocationTargetImportOrdinal:
+			reloc.Module = le.Uint16(entry[4:])
+			reloc.Ordinal = le.Uint16(entry[6:])
+		case RelocationTargetImportName:
+			reloc.Module = le.Uint16(entry[4:])
+			reloc.NameOffset = le.Uint16(entry[6:])
+		case RelocationTargetOsFixup:
+			reloc.OsFixup = le.Uint16(entry[4:])
+		}
+
+		relocs = append(relocs, reloc)
+	}
+
+	return relocs, nil
+}
+
+func parseEntries(data []byte) ([]Entry, error) {
+	le := binary.LittleEndian
+
+	var entries []Entry
+	ordinal := uint16(1)
+	for len(data) >= 2 && data[0] != 0 {
+		count := int(data[0])
+		indicator := data[1]
+		data = data[2:]
+
+		if indicator == 0 {
+			ordinal += uint16(count)
+			continue
+		}
+
+		size := 3
+		if indicator == 0xff {
+			size = 6
+		}
+		if len(data) < size * count {
+			return nil, fmt.Errorf("Truncated NE entry table")
+		}
+
+		for i := 0; i < count; i++ {
+			entry := Entry{
+				Ordinal: ordinal,
+				Flags:   data[0],
+			}
+
+			switch indicator {
+			case 0xff:
+				entry.Type = EntryMovable
+				entry.Segment = data[3]
+				entry.Offset = le.Uint16(data[4:])
+			case 0xfe:
+				entry.Type = EntryConstant
+				entry.Offset = le.Uint16(data[1:])
+			default:
+				entry.Type = EntryFixed
+				entry.Segment = indicator
+				entry.Offset = le.Uint16(data[1:])
+			}
+
+			entries = append(entries, entry)
+			data = data[size:]
+			ordinal++
+		}
+	}
+
+	return entries, nil
+}
+
+func parseNames(data []byte) []Name {
+	var names []Name
+	for len(data) > 0 && data[0] != 0 {
+		size := int(data[0])
+		if len(data) < size + 3 {
+			break
+		}
+
+		names = append(names, Name{
+			Name:    string(data[1:][:size]),
+			Ordinal: binary.LittleEndian.Uint16(data[1 + size:]),
+		})
+		data = data[size + 3:]
+	}
+
+	return names
+}
+
+// Linear address used for a segment in the mapped image. Segments are placed
+// 64 KiB apart, so that selector:offset pairs map to (selector << 16) | offset.
+func SegmentAddress(segment int) uint32 {
+	return uint32(segment) << 16
+}
+
+// Maps every segment at SegmentAddress of its 1-based number. Relocations are
+// not applied, as selectors are only known at runtime.
+func (f *File) Image() (*exe.Image, error) {
+	img := &exe.Image{}
+
+	for i, segment := range f.Segments {
+		size := max(segment.MinAlloc, segment.Length)
+		if size > 0x10000 {
+			size = 0x10000
+		}
+
+		img.Sections = append(img.Sections, exe.ImageSection{
+			Name:            fmt.Sprintf("SEG%d", i + 1),
+			Address:         SegmentAddress(i + 1),
+			Size:            size,
+			Characteristics: segment.Flags.Characteristics(),
+			Offset:          segment.Offset,
+			Data:            segment.Data,
+		})
+	}
+
+	return img, nil
+}
@@ -0,0 +1,103 @@
+package ne
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/dexter3k/watre/explore/ext/exe"
+)
+
+// Builds an NE with a single code segment at sector 2 of the given shift
+func buildNE(t *testing.T, shift uint16, mutate func(h *Header)) []byte {
+	le := binary.LittleEndian
+
+	h := Header{
+		Signature:          Signature,
+		SegmentCount:       1,
+		SegmentTableOffset: uint16(binary.Size(Header{})),
+		AlignmentShift:     shift,
+	}
+	if mutate != nil {
+		mutate(&h)
+	}
+
+	var b bytes.Buffer
+	check := func(err error) {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	check(binary.Write(&b, le, exe.DosHeader{Magic: 0x5a4d, PeHeaderOffset: 0x40}))
+	check(binary.Write(&b, le, h))
+	check(binary.Write(&b, le, [4]uint16{2, 4, 0, 4}))
+
+	sectorSize := 1 << 9
+	if shift != 0 {
+		sectorSize = 1 << shift
+	}
+	b.Write(make([]byte, 2 * sectorSize - b.Len()))
+	b.Write([]byte{0xcb, 0xcb, 0xcb, 0xcb})
+
+	return b.Bytes()
+}
+
+func TestSegmentOffsets(t *testing.T) {
+	tests := []struct {
+		name   string
+		shift  uint16
+		offset uint32
+	}{
+		{"default shift", 0, 0x400},
+		{"shift of 9", 9, 0x400},
+		{"shift of 8", 8, 0x200},
+		{"shift of 10", 10, 0x800},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			file, err := Parse(buildNE(t, test.shift, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			segment := file.Segments[0]
+			if segment.Offset != test.offset {
+				t.Fatalf("Segment at %x, expected %x", segment.Offset, test.offset)
+			}
+			if !bytes.Equal(segment.Data, []byte{0xcb, 0xcb, 0xcb, 0xcb}) {
+				t.Fatalf("Segment data is %02x", segment.Data)
+			}
+		})
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(h *Header)
+		errors string
+	}{
+		{"huge shift", func(h *Header) {
+			h.AlignmentShift = 40
+		}, "alignment shift"},
+		{"segment past the end", func(h *Header) {
+			h.AlignmentShift = 20
+		}, "out of file bounds"},
+		{"segment table past the end", func(h *Header) {
+			h.SegmentCount = 0xffff
+		}, "out of bounds"},
+		{"module references past the end", func(h *Header) {
+			h.ModuleReferenceCount = 0xffff
+		}, "out of bounds"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse(buildNE(t, 0, test.mutate))
+			if err == nil || !strings.Contains(err.Error(), test.errors) {
+				t.Fatalf("Expected an error containing %q, got %v", test.errors, err)
+			}
+		})
+	}
+}
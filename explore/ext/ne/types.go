@@ -0,0 +1,180 @@
+package ne
+
+import (
+	"github.com/dexter3k/watre/explore/ext/exe"
+)
+
+const Signature = 0x454e
+
+type Header struct {
+	Signature      uint16
+	LinkerVersion  uint8
+	LinkerRevision uint8
+
+	// Offsets are relative to the NE header, unless noted
+	EntryTableOffset uint16
+	EntryTableLength uint16
+
+	Crc             uint32
+	Flags           uint16
+	AutoDataSegment uint16
+	HeapSize        uint16
+	StackSize       uint16
+	InitialIP       uint16
+	InitialCS       uint16
+	InitialSP       uint16
+	InitialSS       uint16
+
+	SegmentCount         uint16
+	ModuleReferenceCount uint16
+	NonResidentNamesSize uint16
+
+	SegmentTableOffset    uint16
+	ResourceTableOffset   uint16
+	ResidentNamesOffset   uint16
+	ModuleReferenceOffset uint16
+	ImportedNamesOffset   uint16
+	// Relative to the start of the file
+	NonResidentNamesOffset uint32
+
+	MovableEntryCount uint16
+	AlignmentShift    uint16
+	ResourceSegments  uint16
+
+	TargetOs        uint8
+	OtherFlags      uint8
+	GangloadOffset  uint16
+	GangloadSize    uint16
+	MinCodeSwap     uint16
+	ExpectedVersion uint16
+}
+
+// Shift of sector numbers in the segment and resource tables, zero stands for the default of 9
+func (h *Header) SectorShift() uint16 {
+	if h.AlignmentShift == 0 {
+		return 9
+	}
+	return h.AlignmentShift
+}
+
+type SegmentFlags uint16
+const (
+	SegmentData        SegmentFlags = 0x0001
+	SegmentAllocated   SegmentFlags = 0x0002
+	SegmentLoaded      SegmentFlags = 0x0004
+	SegmentMovable     SegmentFlags = 0x0010
+	SegmentShared      SegmentFlags = 0x0020
+	SegmentPreload     SegmentFlags = 0x0040
+	SegmentReadOnly    SegmentFlags = 0x0080
+	SegmentRelocations SegmentFlags = 0x0100
+	SegmentConforming  SegmentFlags = 0x0200
+	SegmentDiscardable SegmentFlags = 0x1000
+	Segment32Bit       SegmentFlags = 0x2000
+)
+
+func (f SegmentFlags) Has(flags SegmentFlags) bool {
+	return f & flags == flags
+}
+
+// Translates segment flags into equivalent PE section characteristics
+func (f SegmentFlags) Characteristics() exe.SectionCharacteristics {
+	c := exe.SectionRead
+	if f.Has(SegmentData) {
+		c |= exe.SectionInitializedData
+		if !f.Has(SegmentReadOnly) {
+			c |= exe.SectionWrite
+		}
+	} else {
+		c |= exe.SectionCode | exe.SectionExecute
+	}
+	if f.Has(SegmentDiscardable) {
+		c |= exe.SectionDiscardable
+	}
+	if f.Has(SegmentShared) {
+		c |= exe.SectionShared
+	}
+	return c
+}
+
+type RelocationSource uint8
+const (
+	RelocationSourceLowByte   RelocationSource = 0
+	RelocationSourceSelector  RelocationSource = 2
+	RelocationSourcePointer   RelocationSource = 3
+	RelocationSourceOffset    RelocationSource = 5
+	RelocationSourcePointer48 RelocationSource = 11
+	RelocationSourceOffset32  RelocationSource = 13
+)
+
+type RelocationTarget uint8
+const (
+	RelocationTargetInternal      RelocationTarget = 0
+	RelocationTargetImportOrdinal RelocationTarget = 1
+	RelocationTargetImportName    RelocationTarget = 2
+	RelocationTargetOsFixup       RelocationTarget = 3
+)
+
+type Relocation struct {
+	Source   RelocationSource
+	Target   RelocationTarget
+	Additive bool
+	// Start of the chain of source locations for non-additive relocations
+	Offset uint16
+
+	// Internal targets. Segment 0xff refers to a movable entry by ordinal
+	Segment      uint8
+	TargetOffset uint16
+
+	// Imports, 1-based module reference index
+	Module     uint16
+	Ordinal    uint16
+	NameOffset uint16
+
+	OsFixup uint16
+}
+
+type Segment struct {
+	// Absolute file offset, zero if the segment has no data in file
+	Offset   uint32
+	Length   uint32
+	Flags    SegmentFlags
+	MinAlloc uint32
+
+	Data        []byte
+	Relocations []Relocation
+}
+
+type EntryType uint8
+const (
+	EntryUnused   EntryType = 0
+	EntryFixed    EntryType = 1
+	EntryMovable  EntryType = 2
+	EntryConstant EntryType = 3
+)
+
+type Entry struct {
+	Ordinal uint16
+	Type    EntryType
+	Flags   uint8
+	Segment uint8
+	Offset  uint16
+}
+
+type Name struct {
+	Name    string
+	Ordinal uint16
+}
+
+// Segmented 16-bit Windows (or OS/2) executable
+type File struct {
+	Dos          exe.DosHeader
+	HeaderOffset uint32
+	Header       Header
+
+	Segments []Segment
+	Entries  []Entry
+
+	ResidentNames    []Name
+	NonResidentNames []Name
+	ModuleReferences []string
+}
@@ -155,11 +155,35 @@ type Segment struct {
 	Data    []byte
 	Relocs  map[uint32]Relocation
 	Exports map[string]uint32
+	// Static names from LPUBDEF, only unique within the object
+	LocalExports map[string]uint32
+	// Boundary the linker places the segment at
+	Alignment uint32
 }
 
 type Object struct {
 	Name     string
 	Segments [LocationCount]([]*Segment)
+	// Path of the library the object came from, set by the caller
+	Library string
+}
+
+// Alignment given by the A field of SEGDEF attributes
+func segmentAlignment(attributes uint8) uint32 {
+	switch attributes >> 5 {
+	case 2:
+		return 2
+	case 3:
+		return 16
+	case 4:
+		return 256
+	case 5:
+		return 4
+	case 6:
+		return 4096
+	default:
+		return 1
+	}
 }
 
 func (o *Object) GetSegment(location Location, name string) *Segment {
@@ -271,7 +295,8 @@ tagLoop:
 				})
 
 				segment := &Segment{
-					Name: lnames[segmentName],
+					Name:      lnames[segmentName],
+					Alignment: segmentAlignment(segmentAttributes),
 				}
 				if segmentSize > 0 {
 					segment.Data = make([]byte, segmentSize)
@@ -341,6 +366,19 @@ tagLoop:
 						Name:     segments[exportsSegment].Name,
 						Offset:   exportOffset,
 					}
+
+					for _, subSeg := range object.Segments[segments[exportsSegment].Location] {
+						if subSeg.Name != segments[exportsSegment].Name {
+							continue
+						}
+
+						if subSeg.LocalExports == nil {
+							subSeg.LocalExports = map[string]uint32{}
+						}
+						subSeg.LocalExports[exportName] = exportOffset
+
+						break
+					}
 				} else {
 					globalExports[exportName] = SegmentRef{
 						Location: segments[exportsSegment].Location,
@@ -0,0 +1,218 @@
+// Package project reads the description of a matching run, so that it can be
+// repeated and kept under version control:
+//
+//	{
+//		"target": "game.exe",
+//		"libraries": ["C:/WATCOM_10_6/lib386", "C:/WATCOM_10_6/lib386/nt", "extra.obj"],
+//		"include": ["*.lib", "*.obj"],
+//		"exclude": ["math3r.lib", "*/nt/pfs*.lib"],
+//		"priorities": {"clib3r.lib": 10},
+//		"bounds": "00400000-006e29ff",
+//		"selector": "0023",
+//		"regions": {"CODE": "00401000+a7600"},
+//		"output": {"text": "match.txt", "report": "match.json", "idc": "match.idc", "ghidra": "match.py"}
+//	}
+//
+// Relative paths are relative to the project file. Globs are matched
+// case-insensitively against the slash-separated path, or against as many of
+// its trailing components as they have: "math3r.lib" matches the file name,
+// "*/nt/pfs*.lib" the file and the two directories it is in.
+package project
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+type Outputs struct {
+	// Listing printed by omfmatch, stdout when empty
+	Text string `json:"text,omitempty"`
+	// JSON report, see package report
+	Report string `json:"report,omitempty"`
+	// Scripts applying the report to an IDA or Ghidra database
+	IDC    string `json:"idc,omitempty"`
+	Ghidra string `json:"ghidra,omitempty"`
+}
+
+type Project struct {
+	Target string `json:"target"`
+	// Library and object files, or directories searched for them (not recursively)
+	Libraries []string `json:"libraries"`
+
+	// When not empty, only libraries matching one of these are used
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+	// Libraries with higher priority come first and win export collisions.
+	// A library takes the highest priority of the globs it matches, zero if none.
+	Priorities map[string]int `json:"priorities,omitempty"`
+
+	// Range relocations may point into as LOW-HIGH, in hex. Taken from the image when empty.
+	Bounds string `json:"bounds,omitempty"`
+	// Selector of 48-bit far pointers, in hex. Learned from the matches when empty.
+	FlatSelector string `json:"selector,omitempty"`
+	// Overrides of where segments of a location are searched for, as BASE+SIZE in hex
+	Regions map[string]string `json:"regions,omitempty"`
+
+	Output Outputs `json:"output"`
+
+	// CPU profile of the run is written here when set
+	CpuProfile string `json:"cpuprofile,omitempty"`
+}
+
+func Load(path string) (*Project, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Project{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(p); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if p.Target == "" {
+		return nil, fmt.Errorf("%s: No target", path)
+	}
+
+	patterns := slices.Concat(p.Include, p.Exclude)
+	for pattern := range p.Priorities {
+		patterns = append(patterns, pattern)
+	}
+	for _, pattern := range patterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("%s: Invalid glob %q: %w", path, pattern, err)
+		}
+	}
+
+	dir := filepath.Dir(path)
+	resolve := func(path *string) {
+		if *path != "" && !filepath.IsAbs(*path) {
+			*path = filepath.Join(dir, *path)
+		}
+	}
+	resolve(&p.Target)
+	for i := range p.Libraries {
+		resolve(&p.Libraries[i])
+	}
+	resolve(&p.Output.Text)
+	resolve(&p.Output.Report)
+	resolve(&p.Output.IDC)
+	resolve(&p.Output.Ghidra)
+	resolve(&p.CpuProfile)
+
+	return p, nil
+}
+
+// Layout overrides in the form taken by match.Target.Override
+func (p *Project) Layout() []string {
+	var layout []string
+	if p.Bounds != "" {
+		layout = append(layout, "bounds=" + p.Bounds)
+	}
+	if p.FlatSelector != "" {
+		layout = append(layout, "selector=" + p.FlatSelector)
+	}
+	for _, location := range slices.Sorted(maps.Keys(p.Regions)) {
+		layout = append(layout, location + "=" + p.Regions[location])
+	}
+
+	return layout
+}
+
+// Matches the whole path, or as many of its trailing components as the
+// pattern has, so that "nt/*.lib" matches any .lib file in a directory named nt
+func matches(pattern, path string) bool {
+	pattern = strings.ToLower(filepath.ToSlash(pattern))
+	path = strings.ToLower(filepath.ToSlash(path))
+
+	if ok, _ := filepath.Match(pattern, path); ok {
+		return true
+	}
+
+	components := strings.Split(path, "/")
+	count := strings.Count(pattern, "/") + 1
+	if count > len(components) {
+		return false
+	}
+	ok, _ := filepath.Match(pattern, strings.Join(components[len(components) - count:], "/"))
+	return ok
+}
+
+func matchesAny(patterns []string, path string) bool {
+	for _, pattern := range patterns {
+		if matches(pattern, path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *Project) Priority(path string) int {
+	priority, found := 0, false
+	for pattern, value := range p.Priorities {
+		if matches(pattern, path) && (!found || value > priority) {
+			priority, found = value, true
+		}
+	}
+
+	return priority
+}
+
+func (p *Project) Selected(path string) bool {
+	if len(p.Include) > 0 && !matchesAny(p.Include, path) {
+		return false
+	}
+
+	return !matchesAny(p.Exclude, path)
+}
+
+// Library and object files to match, directories expanded to the .lib and .obj
+// files within them. Ordered by priority, then in the order they were listed.
+func (p *Project) LibraryFiles() ([]string, error) {
+	var files []string
+	seen := map[string]struct{}{}
+	add := func(path string) {
+		if _, found := seen[path]; found || !p.Selected(path) {
+			return
+		}
+		seen[path] = struct{}{}
+		files = append(files, path)
+	}
+
+	for _, path := range p.Libraries {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			add(path)
+			continue
+		}
+
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			ext := strings.ToLower(filepath.Ext(entry.Name()))
+			if entry.IsDir() || (ext != ".lib" && ext != ".obj") {
+				continue
+			}
+			add(filepath.Join(path, entry.Name()))
+		}
+	}
+
+	slices.SortStableFunc(files, func(a, b string) int {
+		return p.Priority(b) - p.Priority(a)
+	})
+
+	return files, nil
+}
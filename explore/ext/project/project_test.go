@@ -0,0 +1,188 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "game.json")
+	writeFile(t, path, `{
+		"target": "game.exe",
+		"libraries": ["lib", "/abs/extra.obj"],
+		"output": {"report": "out/match.json"}
+	}`)
+
+	p, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Target != filepath.Join(dir, "game.exe") || p.Output.Report != filepath.Join(dir, "out", "match.json") {
+		t.Errorf("Target is %q, report is %q", p.Target, p.Output.Report)
+	}
+	if len(p.Libraries) != 2 || p.Libraries[0] != filepath.Join(dir, "lib") || p.Libraries[1] != "/abs/extra.obj" {
+		t.Errorf("Libraries are %q", p.Libraries)
+	}
+	if p.Output.Text != "" || p.CpuProfile != "" {
+		t.Errorf("Unset paths are resolved: %q, %q", p.Output.Text, p.CpuProfile)
+	}
+}
+
+func TestLoadMalformed(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		errors  string
+	}{
+		{"not JSON", `target: game.exe`, "invalid character"},
+		{"unknown field", `{"target": "game.exe", "libs": []}`, "unknown field"},
+		{"wrong type", `{"target": "game.exe", "priorities": {"a.lib": "high"}}`, "cannot unmarshal"},
+		{"missing target", `{"libraries": ["lib"]}`, "No target"},
+		{"bad include glob", `{"target": "game.exe", "include": ["[a"]}`, "Invalid glob"},
+		{"bad exclude glob", `{"target": "game.exe", "exclude": ["a\\"]}`, "Invalid glob"},
+		{"bad priority glob", `{"target": "game.exe", "priorities": {"[": 1}}`, "Invalid glob"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "project.json")
+			writeFile(t, path, test.content)
+
+			_, err := Load(path)
+			if err == nil || !strings.Contains(err.Error(), test.errors) {
+				t.Fatalf("Expected an error containing %q, got %v", test.errors, err)
+			}
+		})
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Errorf("Missing project file loaded")
+	}
+}
+
+func TestLayout(t *testing.T) {
+	p := &Project{
+		Bounds:       "00400000-006e29ff",
+		FlatSelector: "0023",
+		Regions:      map[string]string{"DATA": "00500000+1000", "CODE": "00401000+a7600"},
+	}
+
+	expected := []string{"bounds=00400000-006e29ff", "selector=0023", "CODE=00401000+a7600", "DATA=00500000+1000"}
+	if layout := p.Layout(); !slices.Equal(layout, expected) {
+		t.Errorf("Layout is %q, expected %q", layout, expected)
+	}
+	if layout := (&Project{}).Layout(); len(layout) != 0 {
+		t.Errorf("Layout of an empty project is %q", layout)
+	}
+}
+
+func TestLibraryFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"lib386/clib3r.lib", "lib386/math3r.lib", "lib386/MATH387R.LIB", "lib386/readme.txt", "lib386/nt/kernel32.lib", "extra.obj"} {
+		writeFile(t, filepath.Join(dir, name), "")
+	}
+	lib386 := filepath.Join(dir, "lib386")
+
+	tests := []struct {
+		name     string
+		project  Project
+		expected []string
+	}{
+		{"directories are not recursive", Project{
+			Libraries: []string{lib386},
+		}, []string{"MATH387R.LIB", "clib3r.lib", "math3r.lib"}},
+		{"files and duplicates", Project{
+			Libraries: []string{filepath.Join(dir, "extra.obj"), lib386, filepath.Join(lib386, "clib3r.lib")},
+		}, []string{"extra.obj", "MATH387R.LIB", "clib3r.lib", "math3r.lib"}},
+		{"include and exclude", Project{
+			Libraries: []string{lib386, filepath.Join(dir, "extra.obj")},
+			Include:   []string{"*.lib"},
+			Exclude:   []string{"math3*.lib"},
+		}, []string{"clib3r.lib"}},
+		{"priorities", Project{
+			Libraries:  []string{lib386},
+			Priorities: map[string]int{"clib*.lib": 10, "*/lib386/math*.lib": 5, "math3r.lib": 20},
+		}, []string{"math3r.lib", "clib3r.lib", "MATH387R.LIB"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			files, err := test.project.LibraryFiles()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var names []string
+			for _, file := range files {
+				names = append(names, filepath.Base(file))
+			}
+			if !slices.Equal(names, test.expected) {
+				t.Errorf("Files are %q, expected %q", names, test.expected)
+			}
+		})
+	}
+
+	missing := Project{Libraries: []string{filepath.Join(dir, "missing.lib")}}
+	if _, err := missing.LibraryFiles(); err == nil {
+		t.Errorf("Missing library listed")
+	}
+}
+
+func TestWatcomProject(t *testing.T) {
+	p, err := Load("../../projects/watcom-10.6.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path     string
+		selected bool
+	}{
+		{"C:/WATCOM_10_6/lib386/clib3r.lib", true},
+		{"C:/WATCOM_10_6/lib386/math387r.lib", true},
+		{"C:/WATCOM_10_6/lib386/math3r.lib", false},
+		{"C:/WATCOM_10_6/lib386/nt/clib3r.lib", true},
+		{"C:/WATCOM_10_6/lib386/nt/clib3s.lib", false},
+		{"C:/WATCOM_10_6/lib386/clib3s.lib", true},
+		{"C:/WATCOM_10_6/LIB386/NT/PFSN3R.LIB", false},
+	}
+	for _, test := range tests {
+		if selected := p.Selected(test.path); selected != test.selected {
+			t.Errorf("%s selected is %v, expected %v", test.path, selected, test.selected)
+		}
+	}
+
+	// The same layout anywhere else
+	dir := t.TempDir()
+	for _, name := range []string{"lib386/clib3r.lib", "lib386/math3r.lib", "lib386/plibmt3r.lib", "lib386/nt/clib3s.lib", "lib386/nt/kernel32.lib", "lib386/nt/pfsxmt3r.lib"} {
+		writeFile(t, filepath.Join(dir, name), "")
+	}
+	p.Libraries = []string{filepath.Join(dir, "lib386"), filepath.Join(dir, "lib386", "nt")}
+
+	files, err := p.LibraryFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, file := range files {
+		rel, _ := filepath.Rel(dir, file)
+		names = append(names, filepath.ToSlash(rel))
+	}
+	if expected := []string{"lib386/clib3r.lib", "lib386/nt/kernel32.lib"}; !slices.Equal(names, expected) {
+		t.Errorf("Files are %q, expected %q", names, expected)
+	}
+}
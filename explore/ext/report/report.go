@@ -0,0 +1,215 @@
+// Package report turns match results into JSON for other tools to consume.
+// Addresses are virtual addresses of the target, ranges are end-exclusive.
+// Fields are only ever added within a version, anything else bumps it.
+package report
+
+import (
+	"encoding/json"
+	"io"
+	"maps"
+	"slices"
+
+	"github.com/dexter3k/watre/explore/ext/exe"
+	"github.com/dexter3k/watre/explore/ext/match"
+	"github.com/dexter3k/watre/explore/ext/omf"
+)
+
+const Version = 1
+
+type Range struct {
+	Start uint32 `json:"start"`
+	End   uint32 `json:"end"`
+}
+
+type Placement struct {
+	Object  string `json:"object"`
+	Library string `json:"library"`
+	Section string `json:"section"`
+	Segment string `json:"segment"`
+	Range
+
+	Alignment uint32 `json:"alignment"`
+	// One of the match.Confidence values, or "bytes" for placements that
+	// only compared bytes and ignored relocations
+	Confidence string `json:"confidence"`
+}
+
+type Symbol struct {
+	Name    string `json:"name"`
+	Address uint32 `json:"address"`
+	// Known for BSS variables only
+	Size    uint32 `json:"size,omitempty"`
+	Object  string `json:"object"`
+	Library string `json:"library"`
+	// "export", "reference" or "local" for static names, which are only
+	// unique within their object
+	Source string `json:"source"`
+}
+
+type Candidate struct {
+	Globals  map[string]uint32 `json:"globals"`
+	Segments map[string]uint32 `json:"segments"`
+}
+
+type Ambiguity struct {
+	Object     string      `json:"object"`
+	Library    string      `json:"library"`
+	Candidates []Candidate `json:"candidates"`
+}
+
+type Conflict struct {
+	Object  string   `json:"object"`
+	Library string   `json:"library"`
+	Reasons []string `json:"reasons"`
+}
+
+// Part of a section no placement covers
+type Region struct {
+	Section string `json:"section"`
+	Range
+}
+
+type Report struct {
+	Version int    `json:"version"`
+	Target  string `json:"target"`
+	Bounds  Range  `json:"bounds"`
+
+	FlatSelector uint16 `json:"flat_selector,omitempty"`
+
+	Placements []Placement `json:"placements"`
+	Symbols    []Symbol    `json:"symbols"`
+	LinkOrder  []string    `json:"link_order"`
+
+	Ambiguous   []Ambiguity `json:"ambiguous"`
+	Conflicting []Conflict  `json:"conflicting"`
+	Unmatched   []Region    `json:"unmatched"`
+	// Disagreements between relocations, sizes and link order of BSS segments
+	Issues []string `json:"issues"`
+}
+
+// Empty report of a target, for matchers that fill it in themselves
+func New(path string) *Report {
+	return &Report{
+		Version:     Version,
+		Target:      path,
+		Placements:  []Placement{},
+		Symbols:     []Symbol{},
+		LinkOrder:   []string{},
+		Ambiguous:   []Ambiguity{},
+		Conflicting: []Conflict{},
+		Unmatched:   []Region{},
+		Issues:      []string{},
+	}
+}
+
+func Build(path string, target *match.Target, result *match.Result) *Report {
+	r := New(path)
+	r.Bounds = Range{target.LowAddress, target.HighAddress + 1}
+	r.FlatSelector = result.FlatSelector
+	r.LinkOrder = append(r.LinkOrder, result.LinkOrder...)
+	r.Issues = append(r.Issues, result.StaticIssues...)
+
+	for _, placement := range result.Placements {
+		r.Placements = append(r.Placements, Placement{
+			Object:     placement.Object,
+			Library:    placement.Library,
+			Section:    placement.Location.String(),
+			Segment:    placement.Segment,
+			Range:      Range{placement.Address, placement.Address + placement.Size},
+			Alignment:  placement.Alignment,
+			Confidence: placement.Confidence,
+		})
+	}
+
+	sizes := map[string]uint32{}
+	for _, static := range result.Statics {
+		for _, sym := range static.Symbols {
+			if sym.Name != "" {
+				sizes[sym.Name] = sym.Size
+			}
+		}
+	}
+	for _, sym := range result.Symbols {
+		size := sizes[sym.Name]
+		if sym.Source == "local" {
+			size = 0
+		}
+		r.Symbols = append(r.Symbols, Symbol{
+			Name:    sym.Name,
+			Address: sym.Address,
+			Size:    size,
+			Object:  sym.Object,
+			Library: sym.Library,
+			Source:  sym.Source,
+		})
+	}
+
+	for _, ambiguity := range result.Ambiguous {
+		a := Ambiguity{
+			Object:     ambiguity.Object,
+			Library:    ambiguity.Library,
+			Candidates: []Candidate{},
+		}
+		for _, candidate := range ambiguity.Candidates {
+			a.Candidates = append(a.Candidates, Candidate{
+				Globals:  candidate.Globals,
+				Segments: candidate.Locals,
+			})
+		}
+		r.Ambiguous = append(r.Ambiguous, a)
+	}
+	for _, conflict := range result.Conflicting {
+		r.Conflicting = append(r.Conflicting, Conflict{
+			Object:  conflict.Object,
+			Library: conflict.Library,
+			Reasons: append([]string{}, conflict.Reasons...),
+		})
+	}
+
+	r.Unmatched = append(r.Unmatched, unmatched(target, result.Placements)...)
+
+	return r
+}
+
+// Gaps between placements in each searched region. Gaps an aligned
+// placement would start right after are padding and left out. Locations
+// searched in the same region, like data and constants, share its gaps.
+func unmatched(target *match.Target, placements []match.Placement) []Region {
+	boundsOf := map[omf.Location]Range{}
+	for location, region := range target.Regions {
+		boundsOf[location] = Range{region.Base, region.Base + uint32(len(region.Data))}
+	}
+
+	var regions []Region
+	seen := map[Range]struct{}{}
+	for _, location := range slices.Sorted(maps.Keys(target.Regions)) {
+		bounds := boundsOf[location]
+		if _, found := seen[bounds]; found || bounds.Start == bounds.End {
+			continue
+		}
+		seen[bounds] = struct{}{}
+
+		cursor := bounds.Start
+		for _, placement := range placements {
+			start, end := placement.Address, placement.Address + placement.Size
+			if boundsOf[placement.Location] != bounds || end <= cursor || placement.Size == 0 {
+				continue
+			}
+			if start > cursor && exe.AlignUp(cursor, placement.Alignment) < start {
+				regions = append(regions, Region{location.String(), Range{cursor, min(start, bounds.End)}})
+			}
+			cursor = max(cursor, end)
+		}
+		if cursor < bounds.End {
+			regions = append(regions, Region{location.String(), Range{cursor, bounds.End}})
+		}
+	}
+
+	return regions
+}
+
+func (r *Report) Write(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "\t")
+	return encoder.Encode(r)
+}
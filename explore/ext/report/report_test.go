@@ -0,0 +1,154 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/dexter3k/watre/explore/ext/match"
+	"github.com/dexter3k/watre/explore/ext/omf"
+)
+
+// Code at 00401000-00401100, data and constants sharing 00410000-00410100
+func testTarget() *match.Target {
+	data := match.Region{Base: 0x410000, Data: make([]byte, 0x100)}
+	return &match.Target{
+		Regions: map[omf.Location]match.Region{
+			omf.LocationText:  {Base: 0x401000, Data: make([]byte, 0x100)},
+			omf.LocationData:  data,
+			omf.LocationConst: data,
+		},
+		LowAddress:  0x400000,
+		HighAddress: 0x41ffff,
+	}
+}
+
+func TestBuild(t *testing.T) {
+	result := &match.Result{
+		Placements: []match.Placement{
+			{Object: "a.c", Library: "a.lib", Location: omf.LocationText, Segment: "_TEXT", Address: 0x401000, Size: 0x20, Alignment: 4, Confidence: match.ConfidenceUnique},
+			{Object: "b.c", Library: "a.lib", Location: omf.LocationText, Segment: "_TEXT", Address: 0x401022, Size: 0x1e, Alignment: 4, Confidence: match.ConfidenceLinkOrder},
+			{Object: "c.c", Library: "b.lib", Location: omf.LocationText, Segment: "_TEXT", Address: 0x401080, Size: 0x10, Alignment: 16, Confidence: match.ConfidenceUnique},
+			{Object: "a.c", Library: "a.lib", Location: omf.LocationConst, Segment: "CONST", Address: 0x410010, Size: 0x10, Alignment: 1, Confidence: match.ConfidenceUnique},
+		},
+		Symbols: []match.Symbol{
+			{Name: "_a", Address: 0x401000, Object: "a.c", Library: "a.lib", Source: "export"},
+			{Name: "_counter", Address: 0x420000, Object: "a.c", Library: "a.lib", Source: "reference"},
+		},
+		Statics: []match.StaticSegment{
+			{Object: "a.c", Segment: "_BSS", Address: 0x420000, Size: 8, Symbols: []match.StaticSymbol{{Name: "_counter", Address: 0x420000, Size: 8}}},
+		},
+		Ambiguous: []match.Ambiguity{
+			{Object: "d.c", Library: "b.lib", Candidates: []match.Candidate{{Globals: map[string]uint32{"_d": 0x401040}}}},
+		},
+		Conflicting:  []match.Conflict{{Object: "e.c", Library: "b.lib", Reasons: []string{"overlaps a.c"}}},
+		LinkOrder:    []string{"a.c", "b.c"},
+		StaticIssues: []string{"a.c:_BSS is not aligned"},
+		FlatSelector: 0x17,
+	}
+
+	r := Build("game.exe", testTarget(), result)
+
+	if r.Version != Version || r.Target != "game.exe" || r.Bounds != (Range{0x400000, 0x420000}) || r.FlatSelector != 0x17 {
+		t.Errorf("Header is %d %q %+v %04x", r.Version, r.Target, r.Bounds, r.FlatSelector)
+	}
+	if len(r.Placements) != 4 || r.Placements[1].Range != (Range{0x401022, 0x401040}) || r.Placements[3].Section != "CONST" || r.Placements[1].Confidence != match.ConfidenceLinkOrder {
+		t.Errorf("Placements are %+v", r.Placements)
+	}
+	if len(r.Symbols) != 2 || r.Symbols[0].Size != 0 || r.Symbols[1].Size != 8 {
+		t.Errorf("Symbols are %+v", r.Symbols)
+	}
+	if len(r.Ambiguous) != 1 || len(r.Ambiguous[0].Candidates) != 1 || r.Ambiguous[0].Candidates[0].Globals["_d"] != 0x401040 {
+		t.Errorf("Ambiguities are %+v", r.Ambiguous)
+	}
+	if len(r.Conflicting) != 1 || !slices.Equal(r.Conflicting[0].Reasons, []string{"overlaps a.c"}) {
+		t.Errorf("Conflicts are %+v", r.Conflicting)
+	}
+	if !slices.Equal(r.LinkOrder, result.LinkOrder) || !slices.Equal(r.Issues, result.StaticIssues) {
+		t.Errorf("Link order is %q, issues are %q", r.LinkOrder, r.Issues)
+	}
+}
+
+func TestUnmatched(t *testing.T) {
+	tests := []struct {
+		name       string
+		placements []match.Placement
+		expected   []Region
+	}{
+		{"nothing placed", nil, []Region{
+			{"CODE", Range{0x401000, 0x401100}},
+			{"DATA", Range{0x410000, 0x410100}},
+		}},
+		{"padding before aligned placements", []match.Placement{
+			{Location: omf.LocationText, Address: 0x401000, Size: 0x22, Alignment: 1},
+			{Location: omf.LocationText, Address: 0x401030, Size: 0xd0, Alignment: 16},
+		}, []Region{
+			{"DATA", Range{0x410000, 0x410100}},
+		}},
+		{"gaps around placements", []match.Placement{
+			{Location: omf.LocationText, Address: 0x401000, Size: 0x20, Alignment: 4},
+			{Location: omf.LocationText, Address: 0x401040, Size: 0x20, Alignment: 4},
+		}, []Region{
+			{"CODE", Range{0x401020, 0x401040}},
+			{"CODE", Range{0x401060, 0x401100}},
+			{"DATA", Range{0x410000, 0x410100}},
+		}},
+		{"overlapping placements", []match.Placement{
+			{Location: omf.LocationText, Address: 0x401000, Size: 0x80, Alignment: 1},
+			{Location: omf.LocationText, Address: 0x401010, Size: 0x20, Alignment: 1},
+			{Location: omf.LocationText, Address: 0x401080, Size: 0x80, Alignment: 1},
+		}, []Region{
+			{"DATA", Range{0x410000, 0x410100}},
+		}},
+		{"data and constants share gaps", []match.Placement{
+			{Location: omf.LocationData, Address: 0x410000, Size: 0x40, Alignment: 1},
+			{Location: omf.LocationConst, Address: 0x410080, Size: 0x80, Alignment: 1},
+		}, []Region{
+			{"CODE", Range{0x401000, 0x401100}},
+			{"DATA", Range{0x410040, 0x410080}},
+		}},
+		{"empty segments", []match.Placement{
+			{Location: omf.LocationText, Address: 0x401080, Size: 0, Alignment: 1},
+		}, []Region{
+			{"CODE", Range{0x401000, 0x401100}},
+			{"DATA", Range{0x410000, 0x410100}},
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			regions := unmatched(testTarget(), test.placements)
+			if !slices.Equal(regions, test.expected) {
+				t.Errorf("Unmatched regions are %+v, expected %+v", regions, test.expected)
+			}
+		})
+	}
+}
+
+// Consumers never see null where a list is expected
+func TestWriteEmptyLists(t *testing.T) {
+	result := &match.Result{
+		Ambiguous:   []match.Ambiguity{{Object: "a.c"}},
+		Conflicting: []match.Conflict{{Object: "b.c"}},
+	}
+	target := testTarget()
+	target.Regions = map[omf.Location]match.Region{}
+
+	var b bytes.Buffer
+	if err := Build("game.exe", target, result).Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(b.String(), "null") {
+		t.Errorf("Report has nulls:\n%s", b.String())
+	}
+
+	var decoded Report
+	if err := json.Unmarshal(b.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Version != Version || len(decoded.Ambiguous) != 1 || decoded.Ambiguous[0].Candidates == nil || decoded.Placements == nil {
+		t.Errorf("Decoded report is %+v", decoded)
+	}
+}
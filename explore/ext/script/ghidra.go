@@ -0,0 +1,66 @@
+package script
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"github.com/dexter3k/watre/explore/ext/report"
+)
+
+const ghidraPrologue = `# Generated by watre from a match report of %s
+# @category watre
+from ghidra.program.model.data import ArrayDataType, ByteDataType
+from ghidra.program.model.symbol import SourceType
+
+def label(ea, name):
+	createLabel(toAddr(ea), name, True, SourceType.IMPORTED)
+
+def fn(ea, name):
+	addr = toAddr(ea)
+	disassemble(addr)
+	f = getFunctionAt(addr)
+	if f is None:
+		f = createFunction(addr, name)
+	if f is not None:
+		f.setName(name, SourceType.IMPORTED)
+	else:
+		label(ea, name)
+
+def dt(ea, size, name):
+	addr = toAddr(ea)
+	clearListing(addr, addr.add(size - 1))
+	if size == 4:
+		createDWord(addr)
+	elif size == 2:
+		createWord(addr)
+	elif size == 1:
+		createByte(addr)
+	else:
+		createData(addr, ArrayDataType(ByteDataType.dataType, size, 1))
+	label(ea, name)
+
+def cmt(ea, text):
+	setPlateComment(toAddr(ea), text)
+
+`
+
+// Ghidra Python script creating functions and data and naming them after the report
+func WriteGhidra(w io.Writer, r *report.Report) error {
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, ghidraPrologue, r.Target)
+	for _, it := range items(r) {
+		if it.function {
+			fmt.Fprintf(b, "fn(0x%08x, %s)\n", it.address, quote(it.name))
+		} else if it.size != 0 {
+			fmt.Fprintf(b, "dt(0x%08x, 0x%x, %s)\n", it.address, it.size, quote(it.name))
+		} else {
+			fmt.Fprintf(b, "label(0x%08x, %s)\n", it.address, quote(it.name))
+		}
+		if it.comment != "" {
+			fmt.Fprintf(b, "cmt(0x%08x, %s)\n", it.address, quote(it.comment))
+		}
+	}
+
+	return b.Flush()
+}
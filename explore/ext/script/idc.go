@@ -0,0 +1,59 @@
+package script
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"github.com/dexter3k/watre/explore/ext/report"
+)
+
+const idcPrologue = `// Generated by watre from a match report of %s
+#include <idc.idc>
+
+static fn(ea, name)
+{
+	add_func(ea, BADADDR);
+	set_name(ea, name, SN_NOWARN | SN_NOCHECK);
+}
+
+static dt(ea, size, name)
+{
+	del_items(ea, DELIT_SIMPLE, size);
+	if (size == 4) {
+		create_dword(ea);
+	} else if (size == 2) {
+		create_word(ea);
+	} else {
+		create_byte(ea);
+		if (size > 1) {
+			make_array(ea, size);
+		}
+	}
+	set_name(ea, name, SN_NOWARN | SN_NOCHECK);
+}
+
+static main()
+{
+`
+
+// IDA script creating functions and data and naming them after the report
+func WriteIDC(w io.Writer, r *report.Report) error {
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, idcPrologue, r.Target)
+	for _, it := range items(r) {
+		if it.function {
+			fmt.Fprintf(b, "\tfn(0x%08x, %s);\n", it.address, quote(it.name))
+		} else if it.size != 0 {
+			fmt.Fprintf(b, "\tdt(0x%08x, 0x%x, %s);\n", it.address, it.size, quote(it.name))
+		} else {
+			fmt.Fprintf(b, "\tset_name(0x%08x, %s, SN_NOWARN | SN_NOCHECK);\n", it.address, quote(it.name))
+		}
+		if it.comment != "" {
+			fmt.Fprintf(b, "\tset_cmt(0x%08x, %s, 1);\n", it.address, quote(it.comment))
+		}
+	}
+	fmt.Fprintf(b, "}\n")
+
+	return b.Flush()
+}
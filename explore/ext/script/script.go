@@ -0,0 +1,149 @@
+// Package script turns match reports into scripts that apply them to a
+// disassembler database: IDC for IDA and Python for Ghidra.
+package script
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/dexter3k/watre/explore/ext/report"
+)
+
+// Functions start at code segments and at globals within them, data is split
+// at the globals within data segments, each item up to the next one unless
+// its size is known
+type item struct {
+	address uint32
+	// Zero for functions
+	size     uint32
+	function bool
+	name     string
+	comment  string
+}
+
+func isCode(section string) bool {
+	return section == "CODE"
+}
+
+// Label of a segment start no global names, e.g. a_c__TEXT
+func segmentLabel(placement report.Placement) string {
+	name := placement.Object + "_" + placement.Segment
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+func items(r *report.Report) []item {
+	var result []item
+	for _, placement := range r.Placements {
+		code := isCode(placement.Section)
+		comment := fmt.Sprintf("%s %s:%s (%s)", placement.Library, placement.Object, placement.Segment, placement.Confidence)
+
+		// Globals and static names within the segment, the segment start is
+		// always a boundary. Globals win over static names at the same address.
+		starts := map[uint32]string{placement.Start: segmentLabel(placement)}
+		sizes := map[uint32]uint32{}
+		named := map[uint32]struct{}{}
+		for _, sym := range r.Symbols {
+			if sym.Address < placement.Start || sym.Address >= placement.End {
+				continue
+			}
+			if sym.Source == "local" && sym.Object != placement.Object {
+				continue
+			}
+			if _, found := named[sym.Address]; found && sym.Source == "local" {
+				continue
+			}
+			starts[sym.Address] = sym.Name
+			sizes[sym.Address] = sym.Size
+			named[sym.Address] = struct{}{}
+		}
+		addresses := slices.Sorted(maps.Keys(starts))
+
+		for i, address := range addresses {
+			end := placement.End
+			if i + 1 < len(addresses) {
+				end = addresses[i + 1]
+			}
+
+			it := item{
+				address:  address,
+				function: code,
+				name:     starts[address],
+			}
+			if !code {
+				it.size = end - address
+				if size := sizes[address]; size != 0 {
+					it.size = min(it.size, size)
+				}
+			}
+			if address == placement.Start {
+				it.comment = comment
+			}
+			result = append(result, it)
+		}
+	}
+
+	// Globals outside of placed segments, such as those only known from relocations
+	placed := map[uint32]struct{}{}
+	for _, it := range result {
+		placed[it.address] = struct{}{}
+	}
+	for _, sym := range r.Symbols {
+		if _, found := placed[sym.Address]; found {
+			continue
+		}
+		result = append(result, item{
+			address: sym.Address,
+			size:    sym.Size,
+			name:    sym.Name,
+			comment: fmt.Sprintf("%s %s (%s)", sym.Library, sym.Object, sym.Source),
+		})
+	}
+
+	slices.SortStableFunc(result, func(a, b item) int {
+		return cmp.Compare(a.address, b.address)
+	})
+
+	// Overlapping placements start items at the same address, the first one
+	// is kept along with the comments of the rest
+	var deduped []item
+	for _, it := range result {
+		if len(deduped) == 0 || deduped[len(deduped) - 1].address != it.address {
+			deduped = append(deduped, it)
+			continue
+		}
+
+		kept := &deduped[len(deduped) - 1]
+		if it.comment != "" && !strings.Contains(kept.comment, it.comment) {
+			kept.comment = strings.TrimPrefix(kept.comment + "; " + it.comment, "; ")
+		}
+	}
+
+	return deduped
+}
+
+// Double-quoted string literal both IDC and Python understand
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c >= 0x7f:
+			fmt.Fprintf(&b, "\\x%02x", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
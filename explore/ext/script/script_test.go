@@ -0,0 +1,115 @@
+package script
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/dexter3k/watre/explore/ext/report"
+)
+
+func testReport() *report.Report {
+	r := report.New("game.exe")
+	r.Placements = []report.Placement{
+		{Object: "a.c", Library: "a.lib", Section: "CODE", Segment: "_TEXT", Range: report.Range{Start: 0x401000, End: 0x401040}, Confidence: "unique"},
+		// Same bytes matched twice by the byte-only matcher
+		{Object: "b.c", Library: "b.lib", Section: "CODE", Segment: "_TEXT", Range: report.Range{Start: 0x401000, End: 0x401040}, Confidence: "bytes"},
+		{Object: "a.c", Library: "a.lib", Section: "DATA", Segment: "_DATA", Range: report.Range{Start: 0x410000, End: 0x410010}, Confidence: "unique"},
+	}
+	r.Symbols = []report.Symbol{
+		{Name: "_main", Address: 0x401000, Object: "a.c", Library: "a.lib", Source: "export"},
+		{Name: "_table", Address: 0x410004, Size: 4, Object: "a.c", Library: "a.lib", Source: "export"},
+		{Name: "_counter", Address: 0x420000, Size: 8, Object: "a.c", Library: "a.lib", Source: "reference"},
+		{Name: "helper", Address: 0x401020, Object: "a.c", Library: "a.lib", Source: "local"},
+		// Static name at a global, and one of another object in the same range
+		{Name: "main_", Address: 0x401000, Object: "a.c", Library: "a.lib", Source: "local"},
+		{Name: "other", Address: 0x401030, Object: "c.c", Library: "c.lib", Source: "local"},
+	}
+	return r
+}
+
+func TestItems(t *testing.T) {
+	expected := []item{
+		{address: 0x401000, function: true, name: "_main", comment: "a.lib a.c:_TEXT (unique); b.lib b.c:_TEXT (bytes)"},
+		{address: 0x401020, function: true, name: "helper"},
+		{address: 0x401030, name: "other", comment: "c.lib c.c (local)"},
+		{address: 0x410000, size: 4, name: "a_c__DATA", comment: "a.lib a.c:_DATA (unique)"},
+		{address: 0x410004, size: 4, name: "_table"},
+		{address: 0x420000, size: 8, name: "_counter", comment: "a.lib a.c (reference)"},
+	}
+
+	if items := items(testReport()); !slices.Equal(items, expected) {
+		t.Errorf("Items are\n%+v\nexpected\n%+v", items, expected)
+	}
+}
+
+func TestItemsEmpty(t *testing.T) {
+	if items := items(report.New("game.exe")); len(items) != 0 {
+		t.Errorf("Items of an empty report are %+v", items)
+	}
+}
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		s        string
+		expected string
+	}{
+		{"_main", `"_main"`},
+		{`a"b\c`, `"a\"b\\c"`},
+		{"line\nbreak\x7f", `"line\x0abreak\x7f"`},
+		{"\xe9t\xe9", `"\xe9t\xe9"`},
+	}
+
+	for _, test := range tests {
+		if quoted := quote(test.s); quoted != test.expected {
+			t.Errorf("%q quoted as %s, expected %s", test.s, quoted, test.expected)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		name     string
+		write    func(b *bytes.Buffer, r *report.Report) error
+		expected []string
+	}{
+		{"IDC", func(b *bytes.Buffer, r *report.Report) error {
+			return WriteIDC(b, r)
+		}, []string{
+			"\tfn(0x00401000, \"_main\");\n",
+			"\tfn(0x00401020, \"helper\");\n",
+			"\tdt(0x00410004, 0x4, \"_table\");\n",
+			"\tset_name(0x00401030, \"other\", SN_NOWARN | SN_NOCHECK);\n",
+			"\tset_cmt(0x00401000, \"a.lib a.c:_TEXT (unique); b.lib b.c:_TEXT (bytes)\", 1);\n",
+		}},
+		{"Ghidra", func(b *bytes.Buffer, r *report.Report) error {
+			return WriteGhidra(b, r)
+		}, []string{
+			"fn(0x00401000, \"_main\")\n",
+			"fn(0x00401020, \"helper\")\n",
+			"dt(0x00410004, 0x4, \"_table\")\n",
+			"label(0x00401030, \"other\")\n",
+			"cmt(0x00410000, \"a.lib a.c:_DATA (unique)\")\n",
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := test.write(&b, testReport()); err != nil {
+				t.Fatal(err)
+			}
+
+			script := b.String()
+			for _, line := range test.expected {
+				if !strings.Contains(script, line) {
+					t.Errorf("Script has no %q:\n%s", line, script)
+				}
+			}
+			if strings.Count(script, "0x00401000, \"") != 2 {
+				t.Errorf("Duplicate items at 00401000:\n%s", script)
+			}
+		})
+	}
+}
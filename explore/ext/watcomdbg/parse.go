@@ -0,0 +1,296 @@
+package watcomdbg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// Parses Watcom debug info that ends exactly at the end of data,
+// which is how wlink appends it to the executable
+func Parse(data []byte) (*Info, error) {
+	le := binary.LittleEndian
+
+	if len(data) < masterHeaderSize {
+		return nil, fmt.Errorf("No room for Watcom debug master header")
+	}
+
+	info := &Info{}
+	if err := binary.Read(bytes.NewReader(data[len(data) - masterHeaderSize:]), le, &info.Header); err != nil {
+		return nil, err
+	}
+	h := &info.Header
+	if h.Signature != Signature {
+		return nil, fmt.Errorf("Invalid Watcom debug signature: %04x", h.Signature)
+	}
+	if h.ExeMajor != 3 {
+		return nil, fmt.Errorf("Unsupported Watcom debug version: %d.%d", h.ExeMajor, h.ExeMinor)
+	}
+	if h.DebugSize < masterHeaderSize || uint64(h.DebugSize) > uint64(len(data)) {
+		return nil, fmt.Errorf("Invalid Watcom debug size: %d", h.DebugSize)
+	}
+
+	dbg := data[len(data) - int(h.DebugSize):][:h.DebugSize - masterHeaderSize]
+	if int(h.LanguageSize) + int(h.SegmentSize) > len(dbg) {
+		return nil, fmt.Errorf("Watcom debug language and segment tables are out of bounds")
+	}
+
+	languages := dbg[:h.LanguageSize]
+	for len(languages) > 0 {
+		end := bytes.IndexByte(languages, 0)
+		if end == -1 {
+			end = len(languages)
+		}
+		info.Languages = append(info.Languages, string(languages[:end]))
+		languages = languages[min(end + 1, len(languages)):]
+	}
+
+	segments := dbg[h.LanguageSize:][:h.SegmentSize]
+	for len(segments) >= 2 {
+		info.Segments = append(info.Segments, le.Uint16(segments))
+		segments = segments[2:]
+	}
+
+	rest := dbg[h.LanguageSize + h.SegmentSize:]
+	for len(rest) >= sectionHeaderSize {
+		section, size, err := info.parseSection(rest)
+		if err != nil {
+			return nil, fmt.Errorf("Section %d: %w", len(info.Sections), err)
+		}
+
+		info.Sections = append(info.Sections, section)
+		rest = rest[size:]
+	}
+
+	return info, nil
+}
+
+// Name of the language from its offset within the language table
+func (info *Info) languageAt(offset uint16) string {
+	at := 0
+	for _, lang := range info.Languages {
+		if at == int(offset) {
+			return lang
+		}
+		at += len(lang) + 1
+	}
+
+	return ""
+}
+
+func (info *Info) parseSection(data []byte) (Section, int, error) {
+	le := binary.LittleEndian
+
+	var section Section
+	if err := binary.Read(bytes.NewReader(data), le, &section.Header); err != nil {
+		return section, 0, err
+	}
+	h := &section.Header
+	if h.Size < sectionHeaderSize || uint64(h.Size) > uint64(len(data)) {
+		return section, 0, fmt.Errorf("Invalid section size: %d", h.Size)
+	}
+	if !(h.ModulesOffset <= h.GlobalsOffset && h.GlobalsOffset <= h.AddressesOffset && h.AddressesOffset <= h.Size) {
+		return section, 0, fmt.Errorf("Section tables are out of order")
+	}
+	data = data[:h.Size]
+
+	// Module info
+	modules := data[h.ModulesOffset:h.GlobalsOffset]
+	for len(modules) > 0 {
+		if len(modules) < 2 + 3 * 6 + 1 {
+			return section, 0, fmt.Errorf("Truncated module info")
+		}
+
+		module := Module{
+			Language: info.languageAt(le.Uint16(modules)),
+		}
+
+		var blocks [3][][]byte
+		for i := range blocks {
+			demand := modules[2 + i * 6:]
+			var err error
+			blocks[i], err = demandBlocks(data, le.Uint32(demand), int(le.Uint16(demand[4:])))
+			if err != nil {
+				return section, 0, err
+			}
+		}
+		module.Locals, module.Types, module.Lines = blocks[0], blocks[1], blocks[2]
+
+		nameStart := 2 + 3 * 6
+		nameSize := int(modules[nameStart])
+		if len(modules) < nameStart + 1 + nameSize {
+			return section, 0, fmt.Errorf("Truncated module name")
+		}
+		module.Name = string(modules[nameStart + 1:][:nameSize])
+		modules = modules[nameStart + 1 + nameSize:]
+
+		section.Modules = append(section.Modules, module)
+	}
+
+	// Global info
+	globals := data[h.GlobalsOffset:h.AddressesOffset]
+	for len(globals) > 0 {
+		if len(globals) < 10 {
+			return section, 0, fmt.Errorf("Truncated global info")
+		}
+
+		nameSize := int(globals[9])
+		if len(globals) < 10 + nameSize {
+			return section, 0, fmt.Errorf("Truncated global name")
+		}
+
+		section.Globals = append(section.Globals, Global{
+			Name: string(globals[10:][:nameSize]),
+			Address: Address{
+				Offset:  le.Uint32(globals),
+				Segment: le.Uint16(globals[4:]),
+			},
+			Module: le.Uint16(globals[6:]),
+			Kind:   GlobalKind(globals[8]),
+		})
+		globals = globals[10 + nameSize:]
+	}
+
+	// Address info
+	addresses := data[h.AddressesOffset:]
+	for len(addresses) >= 8 {
+		base := Address{
+			Offset:  le.Uint32(addresses),
+			Segment: le.Uint16(addresses[4:]),
+		}
+		count := int(le.Uint16(addresses[6:]))
+		addresses = addresses[8:]
+		if len(addresses) < count * 6 {
+			return section, 0, fmt.Errorf("Truncated address info")
+		}
+
+		for i := 0; i < count; i++ {
+			entry := AddressRange{
+				Address: base,
+				Size:    le.Uint32(addresses),
+				Module:  le.Uint16(addresses[4:]),
+			}
+			section.Addresses = append(section.Addresses, entry)
+
+			base.Offset += entry.Size
+			addresses = addresses[6:]
+		}
+	}
+
+	return section, int(h.Size), nil
+}
+
+// Demand info points to a table of count+1 offsets, consecutive entries delimit the blocks
+func demandBlocks(section []byte, offset uint32, count int) ([][]byte, error) {
+	if count == 0 {
+		return nil, nil
+	}
+
+	le := binary.LittleEndian
+	if uint64(offset) + uint64(count + 1) * 4 > uint64(len(section)) {
+		return nil, fmt.Errorf("Demand info table is out of bounds")
+	}
+
+	var blocks [][]byte
+	for i := 0; i < count; i++ {
+		start := le.Uint32(section[offset + uint32(i) * 4:])
+		end := le.Uint32(section[offset + uint32(i + 1) * 4:])
+		if start > end || uint64(end) > uint64(len(section)) {
+			return nil, fmt.Errorf("Demand block is out of bounds: %08x-%08x", start, end)
+		}
+		blocks = append(blocks, section[start:end])
+	}
+
+	return blocks, nil
+}
+
+func (m *Module) LineBlocks() ([]LineBlock, error) {
+	le := binary.LittleEndian
+
+	var result []LineBlock
+	for _, block := range m.Lines {
+		for len(block) >= 6 {
+			lines := LineBlock{
+				Segment: le.Uint32(block),
+			}
+			count := int(le.Uint16(block[4:]))
+			block = block[6:]
+			if len(block) < count * 6 {
+				return nil, fmt.Errorf("%s: Truncated line info", m.Name)
+			}
+
+			for i := 0; i < count; i++ {
+				lines.Lines = append(lines.Lines, Line{
+					Number: le.Uint16(block),
+					Offset: le.Uint32(block[2:]),
+				})
+				block = block[6:]
+			}
+
+			result = append(result, lines)
+		}
+	}
+
+	return result, nil
+}
+
+// Type records are length-prefixed, the length including the length byte itself
+func (m *Module) TypeRecords() ([]TypeRecord, error) {
+	var result []TypeRecord
+	for _, block := range m.Types {
+		for len(block) > 0 {
+			size := int(block[0])
+			if size < 2 || size > len(block) {
+				return nil, fmt.Errorf("%s: Invalid type record size: %d", m.Name, size)
+			}
+
+			result = append(result, TypeRecord{
+				Kind: block[1],
+				Data: block[2:size],
+			})
+			block = block[size:]
+		}
+	}
+
+	return result, nil
+}
+
+// Returns the name carried by NAME class records
+func (r TypeRecord) Name() (string, bool) {
+	if r.Class() != TypeName {
+		return "", false
+	}
+
+	data := r.Data
+	skipIndex := func() bool {
+		if len(data) == 0 {
+			return false
+		}
+		if data[0] & 0x80 != 0 {
+			if len(data) < 2 {
+				return false
+			}
+			data = data[2:]
+		} else {
+			data = data[1:]
+		}
+		return true
+	}
+
+	switch r.Kind & 0x0f {
+	case 0: // SCALAR: scalar type byte, then the name
+		if len(data) < 1 {
+			return "", false
+		}
+		data = data[1:]
+	case 1: // SCOPE: just the name
+	case 2: // NAME: scope index, type index, then the name
+		if !skipIndex() || !skipIndex() {
+			return "", false
+		}
+	default:
+		return "", false
+	}
+
+	return string(data), true
+}
@@ -0,0 +1,232 @@
+package watcomdbg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+// Offsets of the parts of the debug info built by buildInfo
+const (
+	testSectionStart = 4 + 2 + 2
+	testModuleStart  = testSectionStart + 52
+	testGlobalStart  = testSectionStart + 79
+	testAddressStart = testSectionStart + 94
+)
+
+// Debug info of one C module with a single type, line, global and address range,
+// appended to a few bytes of executable
+func buildInfo() []byte {
+	le := binary.LittleEndian
+
+	var section bytes.Buffer
+	binary.Write(&section, le, SectionHeader{
+		ModulesOffset:   52,
+		GlobalsOffset:   79,
+		AddressesOffset: 94,
+		Size:            108,
+	})
+	// Demand info tables of types and lines
+	binary.Write(&section, le, [4]uint32{34, 40, 40, 52})
+	// int
+	section.Write([]byte{6, 0x10, 0x07, 'i', 'n', 't'})
+	// Line 10 at the start of segment 1
+	binary.Write(&section, le, struct {
+		Segment uint32
+		Count   uint16
+		Number  uint16
+		Offset  uint32
+	}{1, 1, 10, 0})
+	// Module, locals, types and lines demand info, name
+	binary.Write(&section, le, struct {
+		Language uint16
+		Demand   [3]struct {
+			Offset uint32
+			Count  uint16
+		}
+	}{0, [3]struct {
+		Offset uint32
+		Count  uint16
+	}{{0, 0}, {18, 1}, {26, 1}}})
+	section.Write([]byte("\x06main.c"))
+	// Global _main at 1:10 from module 0
+	binary.Write(&section, le, struct {
+		Offset  uint32
+		Segment uint16
+		Module  uint16
+		Kind    GlobalKind
+	}{0x10, 1, 0, GlobalCode})
+	section.Write([]byte("\x05_main"))
+	// 20 bytes of segment 1 come from module 0
+	binary.Write(&section, le, struct {
+		Offset  uint32
+		Segment uint16
+		Count   uint16
+		Size    uint32
+		Module  uint16
+	}{0, 1, 1, 0x20, 0})
+
+	var b bytes.Buffer
+	b.WriteString("MZ\x90\x00")
+	b.WriteString("C\x00")
+	binary.Write(&b, le, uint16(1))
+	b.Write(section.Bytes())
+	binary.Write(&b, le, MasterHeader{
+		Signature:    Signature,
+		ExeMajor:     3,
+		ObjMajor:     1,
+		LanguageSize: 2,
+		SegmentSize:  2,
+		DebugSize:    uint32(b.Len() - 4 + masterHeaderSize),
+	})
+
+	return b.Bytes()
+}
+
+func TestParse(t *testing.T) {
+	info, err := Parse(buildInfo())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(info.Languages) != 1 || info.Languages[0] != "C" || len(info.Segments) != 1 || info.Segments[0] != 1 {
+		t.Errorf("Languages are %q, segments are %v", info.Languages, info.Segments)
+	}
+	if len(info.Sections) != 1 {
+		t.Fatalf("%d sections", len(info.Sections))
+	}
+	section := info.Sections[0]
+
+	if len(section.Modules) != 1 || section.Modules[0].Name != "main.c" || section.Modules[0].Language != "C" {
+		t.Fatalf("Modules are %+v", section.Modules)
+	}
+	module := section.Modules[0]
+
+	types, err := module.TypeRecords()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(types) != 1 {
+		t.Fatalf("Types are %+v", types)
+	}
+	if name, ok := types[0].Name(); !ok || name != "int" {
+		t.Errorf("Type is named %q", name)
+	}
+
+	lines, err := module.LineBlocks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 1 || len(lines[0].Lines) != 1 || lines[0].Lines[0].Number != 10 {
+		t.Errorf("Lines are %+v", lines)
+	}
+
+	if len(section.Globals) != 1 || section.Globals[0].Name != "_main" || section.Globals[0].Address != (Address{0x10, 1}) || !section.Globals[0].Kind.IsCode() {
+		t.Errorf("Globals are %+v", section.Globals)
+	}
+	if len(section.Addresses) != 1 || section.Addresses[0].Size != 0x20 {
+		t.Errorf("Addresses are %+v", section.Addresses)
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	le := binary.LittleEndian
+	master := func(data []byte) []byte {
+		return data[len(data) - masterHeaderSize:]
+	}
+
+	tests := []struct {
+		name   string
+		mutate func(data []byte) []byte
+		errors string
+	}{
+		{"too short", func(data []byte) []byte {
+			return data[len(data) - 4:]
+		}, "No room"},
+		{"bad signature", func(data []byte) []byte {
+			le.PutUint16(master(data), 0x1234)
+			return data
+		}, "signature"},
+		{"unknown version", func(data []byte) []byte {
+			master(data)[2] = 4
+			return data
+		}, "version"},
+		{"debug size past the start", func(data []byte) []byte {
+			le.PutUint32(master(data)[10:], 0x10000)
+			return data
+		}, "debug size"},
+		{"debug size within the master header", func(data []byte) []byte {
+			le.PutUint32(master(data)[10:], 4)
+			return data
+		}, "debug size"},
+		{"language table past the end", func(data []byte) []byte {
+			le.PutUint16(master(data)[6:], 0xffff)
+			return data
+		}, "out of bounds"},
+		{"section size past the end", func(data []byte) []byte {
+			le.PutUint32(data[testSectionStart + 12:], 0x1000)
+			return data
+		}, "section size"},
+		{"section tables out of order", func(data []byte) []byte {
+			le.PutUint32(data[testSectionStart + 4:], 100)
+			return data
+		}, "out of order"},
+		{"demand table past the section", func(data []byte) []byte {
+			le.PutUint32(data[testModuleStart + 8:], 0xfffffff0)
+			return data
+		}, "Demand info table"},
+		{"demand block past the section", func(data []byte) []byte {
+			le.PutUint32(data[testSectionStart + 22:], 0x1000)
+			return data
+		}, "Demand block"},
+		{"truncated global name", func(data []byte) []byte {
+			data[testGlobalStart + 9] = 0x40
+			return data
+		}, "global name"},
+		{"truncated address info", func(data []byte) []byte {
+			le.PutUint16(data[testAddressStart + 6:], 0x100)
+			return data
+		}, "address info"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse(test.mutate(buildInfo()))
+			if err == nil || !strings.Contains(err.Error(), test.errors) {
+				t.Fatalf("Expected an error containing %q, got %v", test.errors, err)
+			}
+		})
+	}
+}
+
+func TestModuleMalformed(t *testing.T) {
+	tests := []struct {
+		name   string
+		module Module
+		parse  func(m *Module) error
+		errors string
+	}{
+		{"line count past the block", Module{Lines: [][]byte{{1, 0, 0, 0, 0x10, 0}}}, func(m *Module) error {
+			_, err := m.LineBlocks()
+			return err
+		}, "Truncated line info"},
+		{"type record past the block", Module{Types: [][]byte{{0x20, 0x10, 0x07}}}, func(m *Module) error {
+			_, err := m.TypeRecords()
+			return err
+		}, "type record size"},
+		{"empty type record", Module{Types: [][]byte{{0x01}}}, func(m *Module) error {
+			_, err := m.TypeRecords()
+			return err
+		}, "type record size"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.parse(&test.module)
+			if err == nil || !strings.Contains(err.Error(), test.errors) {
+				t.Fatalf("Expected an error containing %q, got %v", test.errors, err)
+			}
+		})
+	}
+}
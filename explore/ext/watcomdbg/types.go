@@ -0,0 +1,156 @@
+package watcomdbg
+
+import (
+	"fmt"
+)
+
+const Signature = 0x8386
+
+const (
+	masterHeaderSize  = 14
+	sectionHeaderSize = 18
+)
+
+// Located at the very end of the debug info
+type MasterHeader struct {
+	Signature    uint16
+	ExeMajor     uint8
+	ExeMinor     uint8
+	ObjMajor     uint8
+	ObjMinor     uint8
+	LanguageSize uint16
+	SegmentSize  uint16
+	// Size of the whole debug info, master header included
+	DebugSize uint32
+}
+
+type SectionHeader struct {
+	ModulesOffset   uint32
+	GlobalsOffset   uint32
+	AddressesOffset uint32
+	Size            uint32
+	Id              uint16
+}
+
+// Segmented address as stored in the debug info. For PE images
+// the segment is the 1-based number of the section.
+type Address struct {
+	Offset  uint32
+	Segment uint16
+}
+
+func (a Address) String() string {
+	return fmt.Sprintf("%04x:%08x", a.Segment, a.Offset)
+}
+
+type GlobalKind uint8
+const (
+	GlobalStatic GlobalKind = 0x01
+	GlobalData   GlobalKind = 0x02
+	GlobalCode   GlobalKind = 0x04
+)
+
+func (k GlobalKind) IsStatic() bool {
+	return k & GlobalStatic != 0
+}
+
+func (k GlobalKind) IsData() bool {
+	return k & GlobalData != 0
+}
+
+func (k GlobalKind) IsCode() bool {
+	return k & GlobalCode != 0
+}
+
+type Global struct {
+	Name    string
+	Address Address
+	// Index of the module within the section
+	Module uint16
+	Kind   GlobalKind
+}
+
+// Address info maps ranges of each segment to the modules that produced them
+type AddressRange struct {
+	Address Address
+	Size    uint32
+	Module  uint16
+}
+
+type Line struct {
+	Number uint16
+	Offset uint32
+}
+
+type LineBlock struct {
+	Segment uint32
+	Lines   []Line
+}
+
+type TypeClass uint8
+const (
+	TypeName        TypeClass = 0x10
+	TypeArray       TypeClass = 0x20
+	TypeSubrange    TypeClass = 0x30
+	TypePointer     TypeClass = 0x40
+	TypeEnumeration TypeClass = 0x50
+	TypeStructure   TypeClass = 0x60
+	TypeProcedure   TypeClass = 0x70
+	TypeCharBlock   TypeClass = 0x80
+)
+
+func (c TypeClass) String() string {
+	switch c {
+	case TypeName:
+		return "NAME"
+	case TypeArray:
+		return "ARRAY"
+	case TypeSubrange:
+		return "SUBRANGE"
+	case TypePointer:
+		return "POINTER"
+	case TypeEnumeration:
+		return "ENUMERATION"
+	case TypeStructure:
+		return "STRUCTURE"
+	case TypeProcedure:
+		return "PROCEDURE"
+	case TypeCharBlock:
+		return "CHAR_BLOCK"
+	default:
+		return fmt.Sprintf("TypeClass(%02x)", uint8(c))
+	}
+}
+
+type TypeRecord struct {
+	Kind uint8
+	Data []byte
+}
+
+func (r TypeRecord) Class() TypeClass {
+	return TypeClass(r.Kind & 0xf0)
+}
+
+// Demand-loaded info is kept as raw blocks, to be decoded on request
+type Module struct {
+	Name     string
+	Language string
+
+	Locals [][]byte
+	Types  [][]byte
+	Lines  [][]byte
+}
+
+type Section struct {
+	Header    SectionHeader
+	Modules   []Module
+	Globals   []Global
+	Addresses []AddressRange
+}
+
+type Info struct {
+	Header    MasterHeader
+	Languages []string
+	Segments  []uint16
+	Sections  []Section
+}